@@ -5,6 +5,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"io"
 )
 
@@ -129,3 +130,37 @@ func AesDecryptCFB(encrypted []byte, key []byte) (decrypted []byte) {
 	stream.XORKeyStream(encrypted, encrypted)
 	return encrypted
 }
+
+// =================== GCM ======================
+// AesEncryptGCM 使用随机 nonce 加密并附带认证标签，输出为 nonce+密文。
+func AesEncryptGCM(origData []byte, key []byte) (encrypted []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, origData, nil), nil
+}
+
+// AesDecryptGCM 解密 AesEncryptGCM 的输出，密钥错误或数据被篡改时返回错误。
+func AesDecryptGCM(encrypted []byte, key []byte) (decrypted []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, encrypted[:gcm.NonceSize()], encrypted[gcm.NonceSize():], nil)
+}
@@ -1,3 +1,28 @@
 package db
 
+import (
+	"database/sql/driver"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Array 以 JSON 数组保存在单个列中，列类型见 jsonDBDataType。
+// 可使用 JSONContains 查询是否包含某个元素。
 type Array[T any] []T
+
+func (a Array[T]) Value() (driver.Value, error) {
+	return jsonValue(a)
+}
+
+func (a *Array[T]) Scan(v any) error {
+	return jsonScan(a, v)
+}
+
+func (Array[T]) GormDataType() string {
+	return "json"
+}
+
+func (Array[T]) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return jsonDBDataType(db)
+}
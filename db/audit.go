@@ -0,0 +1,440 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	uslog "github.com/nzlov/utils/slog"
+	"github.com/nzlov/utils/zinc"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditRedacted 为脱敏字段记录的值。
+const AuditRedacted = "***"
+
+type actorKey struct{}
+
+var _actorKey = actorKey{}
+
+// ActorCtx 设置当前操作者，审计日志中记录为 Actor。
+func ActorCtx(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, _actorKey, actor)
+}
+
+// ActorFrom 获取当前操作者。
+func ActorFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	a, _ := ctx.Value(_actorKey).(string)
+	return a
+}
+
+type AuditChange struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// AuditLog 一条记录的一次变更，Changes 为 列名 -> 新旧值。
+type AuditLog struct {
+	Model
+	Table    string `gorm:"index:idx_audit_logs_record"`
+	RecordID string `gorm:"index:idx_audit_logs_record"`
+	Action   string
+	Actor    string `gorm:"index"`
+	Changes  Map[string, AuditChange]
+}
+
+// AuditSink 保存审计日志，在产生变更的语句所在事务中调用，
+// ctx 中的 db 即该语句使用的连接（事务），可通过 For(ctx) 写入同一事务。
+type AuditSink interface {
+	Write(ctx context.Context, logs []AuditLog) error
+}
+
+type AuditSinkFunc func(ctx context.Context, logs []AuditLog) error
+
+func (f AuditSinkFunc) Write(ctx context.Context, logs []AuditLog) error {
+	return f(ctx, logs)
+}
+
+// DBAuditSink 将审计日志写入 audit_logs 表，与业务变更在同一事务中提交。
+// 使用前需要 AutoMigrate(&AuditLog{})。
+type DBAuditSink struct{}
+
+func (DBAuditSink) Write(ctx context.Context, logs []AuditLog) error {
+	db, err := TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Create(&logs).Error
+}
+
+// ZincAuditSink 将审计日志推送到 zinc 索引，推送为异步批量进行，不随业务事务回滚。
+// Write 在事务回调中执行，不等待推送：zinc 缓冲已满时丢弃日志并记录 audit zinc buffer full 警告（dropped 为丢弃条数），避免阻塞事务。
+type ZincAuditSink struct {
+	Config *zinc.Config
+}
+
+func (s ZincAuditSink) Write(ctx context.Context, logs []AuditLog) error {
+	dropped := 0
+	for _, l := range logs {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return err
+		}
+		if !s.Config.TryPushStr(string(data) + "\n") {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		uslog.For(ctx).Warn("audit zinc buffer full", "dropped", dropped)
+	}
+	return nil
+}
+
+type AuditConfig struct {
+	Models map[reflect.Type]bool
+	Redact map[string]bool
+}
+
+type AuditOption func(*AuditConfig)
+
+// WithAuditModels 只审计指定的模型，默认审计所有内嵌 Model 的模型。
+func WithAuditModels(models ...any) AuditOption {
+	return func(cfg *AuditConfig) {
+		if cfg.Models == nil {
+			cfg.Models = map[reflect.Type]bool{}
+		}
+		for _, m := range models {
+			cfg.Models[reflect.Indirect(reflect.ValueOf(m)).Type()] = true
+		}
+	}
+}
+
+// WithAuditRedact 对所有模型中指定名称（字段名或列名）的字段脱敏，如 password。
+// 也可以在字段上使用 audit:"redact" 标签脱敏，audit:"-" 不记录该字段。Encrypted 字段始终脱敏。
+func WithAuditRedact(fields ...string) AuditOption {
+	return func(cfg *AuditConfig) {
+		for _, f := range fields {
+			cfg.Redact[f] = true
+		}
+	}
+}
+
+// NewAuditOp 注册审计插件，通过 gorm 回调记录创建、更新、删除前后的字段值，操作者取自 ActorCtx。
+// 更新与删除前会按相同条件查询受影响的记录，更新后再按主键查询新值，只记录发生变化的字段。
+// Raw/Exec 执行的原生 SQL 不做记录。
+func NewAuditOp(sink AuditSink, ops ...AuditOption) Option {
+	p := &auditPlugin{sink: sink, cfg: AuditConfig{Redact: map[string]bool{}}}
+	for _, op := range ops {
+		op(&p.cfg)
+	}
+	return func(cfg *gorm.Config) {
+		if cfg.Plugins == nil {
+			cfg.Plugins = map[string]gorm.Plugin{}
+		}
+		cfg.Plugins[p.Name()] = p
+	}
+}
+
+type auditPlugin struct {
+	sink AuditSink
+	cfg  AuditConfig
+}
+
+func (p *auditPlugin) Name() string {
+	return "nzlov:audit"
+}
+
+const auditOldKey = "nzlov:audit_old"
+
+func (p *auditPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:create").Before("gorm:commit_or_rollback_transaction").
+			Register("nzlov:audit_create", p.afterCreate),
+		cb.Update().Before("gorm:update").After("gorm:begin_transaction").
+			Register("nzlov:audit_before_update", p.before),
+		cb.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").
+			Register("nzlov:audit_update", p.afterUpdate),
+		cb.Delete().Before("gorm:delete").After("gorm:begin_transaction").
+			Register("nzlov:audit_before_delete", p.before),
+		cb.Delete().After("gorm:delete").Before("gorm:commit_or_rollback_transaction").
+			Register("nzlov:audit_delete", p.afterDelete),
+	)
+}
+
+var _modelType = reflect.TypeOf(Model{})
+
+// audited 判断语句的模型是否需要审计。
+func (p *auditPlugin) audited(db *gorm.DB) bool {
+	s := db.Statement.Schema
+	if db.Error != nil || s == nil || s.ModelType == reflect.TypeOf(AuditLog{}) {
+		return false
+	}
+	if p.cfg.Models != nil {
+		return p.cfg.Models[s.ModelType]
+	}
+	for i := 0; i < s.ModelType.NumField(); i++ {
+		if f := s.ModelType.Field(i); f.Anonymous && f.Type == _modelType {
+			return true
+		}
+	}
+	return false
+}
+
+// fields 返回需要记录的字段，忽略 updated_at 与 audit:"-" 的字段。
+func (p *auditPlugin) fields(s *schema.Schema) []*schema.Field {
+	fs := []*schema.Field{}
+	for _, f := range s.Fields {
+		if f.DBName == "" || f.AutoUpdateTime > 0 || f.Tag.Get("audit") == "-" {
+			continue
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+type auditRedacted interface {
+	auditRedact()
+}
+
+func (p *auditPlugin) value(ctx context.Context, f *schema.Field, rv reflect.Value) any {
+	v, _ := f.ValueOf(ctx, rv)
+	if _, ok := v.(auditRedacted); ok || f.Tag.Get("audit") == "redact" || p.cfg.Redact[f.Name] || p.cfg.Redact[f.DBName] {
+		return AuditRedacted
+	}
+	return v
+}
+
+func recordID(ctx context.Context, s *schema.Schema, rv reflect.Value) string {
+	if s.PrioritizedPrimaryField == nil {
+		return ""
+	}
+	v, _ := s.PrioritizedPrimaryField.ValueOf(ctx, rv)
+	return fmt.Sprint(v)
+}
+
+// rows 返回语句中的结构体记录。
+func rows(rv reflect.Value) []reflect.Value {
+	rs := []reflect.Value{}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if e := reflect.Indirect(rv.Index(i)); e.Kind() == reflect.Struct {
+				rs = append(rs, e)
+			}
+		}
+	case reflect.Struct:
+		rs = append(rs, rv)
+	}
+	return rs
+}
+
+func (p *auditPlugin) write(db *gorm.DB, logs []AuditLog) {
+	if len(logs) == 0 {
+		return
+	}
+	ctx := Ctx(db.Statement.Context, db.Session(&gorm.Session{NewDB: true, Initialized: true}))
+	if err := p.sink.Write(ctx, logs); err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+	}
+}
+
+func (p *auditPlugin) afterCreate(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	stmt := db.Statement
+	ctx, actor, fs := stmt.Context, ActorFrom(stmt.Context), p.fields(stmt.Schema)
+
+	logs := []AuditLog{}
+	for _, rv := range rows(stmt.ReflectValue) {
+		changes := Map[string, AuditChange]{}
+		for _, f := range fs {
+			changes[f.DBName] = AuditChange{New: p.value(ctx, f, rv)}
+		}
+		logs = append(logs, AuditLog{
+			Table:    stmt.Table,
+			RecordID: recordID(ctx, stmt.Schema, rv),
+			Action:   AuditCreate,
+			Actor:    actor,
+			Changes:  changes,
+		})
+	}
+	p.write(db, logs)
+}
+
+// load 按条件查询记录，在语句所在的连接（事务）中执行。
+func (p *auditPlugin) load(db *gorm.DB, where []clause.Expression) (reflect.Value, error) {
+	stmt := db.Statement
+	objs := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	tx := db.Session(&gorm.Session{NewDB: true}).Table(stmt.Table)
+	if stmt.Unscoped {
+		tx = tx.Unscoped()
+	}
+	if len(where) > 0 {
+		tx.Statement.AddClause(clause.Where{Exprs: where})
+	}
+	if err := tx.Find(objs.Interface()).Error; err != nil {
+		return objs, err
+	}
+	return objs.Elem(), nil
+}
+
+// before 在更新、删除前查询受影响记录的旧值。
+func (p *auditPlugin) before(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	stmt := db.Statement
+	where := []clause.Expression{}
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if w, ok := c.Expression.(clause.Where); ok {
+			where = append(where, w.Exprs...)
+		}
+	}
+
+	// 模型上的主键条件由 gorm 在执行阶段追加，这里需要自行加入
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk != nil && (stmt.ReflectValue.Kind() == reflect.Struct || stmt.ReflectValue.Kind() == reflect.Slice) {
+		ids := []any{}
+		for _, rv := range rows(stmt.ReflectValue) {
+			if v, zero := pk.ValueOf(stmt.Context, rv); !zero {
+				ids = append(ids, v)
+			}
+		}
+		if len(ids) > 0 {
+			where = append(where, clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids})
+		}
+	}
+	// 没有条件时 gorm 会拒绝执行（或 AllowGlobalUpdate 时影响全表），不加载旧值
+	if len(where) == 0 {
+		return
+	}
+
+	old, err := p.load(db, where)
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+	db.InstanceSet(auditOldKey, old)
+}
+
+func (p *auditPlugin) old(db *gorm.DB) (reflect.Value, bool) {
+	v, ok := db.InstanceGet(auditOldKey)
+	if !ok {
+		return reflect.Value{}, false
+	}
+	old := v.(reflect.Value)
+	return old, old.Len() > 0
+}
+
+func jsonEqual(a, b any) bool {
+	da, err1 := json.Marshal(a)
+	db, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(da, db)
+}
+
+func (p *auditPlugin) afterUpdate(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	old, ok := p.old(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	ctx, actor, fs := stmt.Context, ActorFrom(stmt.Context), p.fields(stmt.Schema)
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return
+	}
+
+	ids := make([]any, old.Len())
+	for i := range ids {
+		ids[i], _ = pk.ValueOf(ctx, old.Index(i))
+	}
+	cur, err := p.load(db, []clause.Expression{
+		clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: pk.DBName}, Values: ids},
+	})
+	if err != nil {
+		db.AddError(fmt.Errorf("audit: %w", err))
+		return
+	}
+	curs := map[string]reflect.Value{}
+	for i := 0; i < cur.Len(); i++ {
+		curs[recordID(ctx, stmt.Schema, cur.Index(i))] = cur.Index(i)
+	}
+
+	logs := []AuditLog{}
+	for i := 0; i < old.Len(); i++ {
+		o := old.Index(i)
+		id := recordID(ctx, stmt.Schema, o)
+		n, ok := curs[id]
+		if !ok {
+			continue
+		}
+		changes := Map[string, AuditChange]{}
+		for _, f := range fs {
+			ov, _ := f.ValueOf(ctx, o)
+			nv, _ := f.ValueOf(ctx, n)
+			if jsonEqual(ov, nv) {
+				continue
+			}
+			changes[f.DBName] = AuditChange{Old: p.value(ctx, f, o), New: p.value(ctx, f, n)}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		logs = append(logs, AuditLog{
+			Table:    stmt.Table,
+			RecordID: id,
+			Action:   AuditUpdate,
+			Actor:    actor,
+			Changes:  changes,
+		})
+	}
+	p.write(db, logs)
+}
+
+func (p *auditPlugin) afterDelete(db *gorm.DB) {
+	if !p.audited(db) {
+		return
+	}
+	old, ok := p.old(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	ctx, actor, fs := stmt.Context, ActorFrom(stmt.Context), p.fields(stmt.Schema)
+
+	logs := []AuditLog{}
+	for i := 0; i < old.Len(); i++ {
+		o := old.Index(i)
+		changes := Map[string, AuditChange]{}
+		for _, f := range fs {
+			changes[f.DBName] = AuditChange{Old: p.value(ctx, f, o)}
+		}
+		logs = append(logs, AuditLog{
+			Table:    stmt.Table,
+			RecordID: recordID(ctx, stmt.Schema, o),
+			Action:   AuditDelete,
+			Actor:    actor,
+			Changes:  changes,
+		})
+	}
+	p.write(db, logs)
+}
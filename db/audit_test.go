@@ -0,0 +1,106 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	uslog "github.com/nzlov/utils/slog"
+	"github.com/nzlov/utils/zinc"
+)
+
+type auditTestModel struct {
+	Model
+	Name     string
+	Age      int
+	Password string `audit:"redact"`
+	Note     string `audit:"-"`
+}
+
+// TestAudit 确认创建、更新、删除均记录差异与操作者，并对脱敏字段隐藏原值。
+func TestAudit(t *testing.T) {
+	db := openTestDB(t, NewAuditOp(DBAuditSink{}))
+	if err := db.AutoMigrate(&auditTestModel{}, &AuditLog{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	ctx := ActorCtx(testCtx(db), "alice")
+
+	objs := []auditTestModel{{Name: "a", Age: 1, Password: "p1"}, {Name: "b", Age: 2}}
+	if err := For(ctx).Create(&objs).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := For(ctx).Model(&objs[0]).Updates(map[string]any{"age": 3, "password": "p2", "note": "x"}).Error; err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	// 按条件批量更新，未发生变化的记录不记录
+	if err := For(ctx).Model(&auditTestModel{}).Where("age >= ?", 2).Update("name", "b").Error; err != nil {
+		t.Fatalf("批量更新失败: %v", err)
+	}
+	if err := Tx(ctx, func(ctx context.Context) error {
+		return For(ctx).Delete(&auditTestModel{}, objs[1].ID).Error
+	}); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+
+	logs := []AuditLog{}
+	if err := db.Order("id").Find(&logs).Error; err != nil {
+		t.Fatalf("读取审计日志失败: %v", err)
+	}
+	actions := []string{}
+	for _, l := range logs {
+		actions = append(actions, l.Action+":"+l.RecordID)
+		if l.Actor != "alice" || l.Table != "audit_test_models" {
+			t.Fatalf("审计日志不正确: %+v", l)
+		}
+		if _, ok := l.Changes["note"]; ok {
+			t.Fatalf("audit:\"-\" 字段不应记录: %+v", l.Changes)
+		}
+	}
+	if strings.Join(actions, ",") != "create:1,create:2,update:1,update:1,delete:2" {
+		t.Fatalf("审计日志不正确: %v", actions)
+	}
+
+	if c := logs[0].Changes["password"]; c.New != AuditRedacted {
+		t.Fatalf("创建时应脱敏: %+v", c)
+	}
+	up := logs[2].Changes
+	if len(up) != 2 || up["age"].Old != float64(1) || up["age"].New != float64(3) || up["password"].Old != AuditRedacted {
+		t.Fatalf("更新差异不正确: %+v", up)
+	}
+	del := logs[len(logs)-1]
+	if del.Action != AuditDelete || del.Changes["name"].Old != "b" {
+		t.Fatalf("删除记录不正确: %+v", del)
+	}
+}
+
+// TestAuditOptions 确认 WithAuditModels 按类型而不是类型名匹配，ZincAuditSink 缓冲不可用时丢弃并告警而不阻塞。
+func TestAuditOptions(t *testing.T) {
+	cfg := AuditConfig{}
+	WithAuditModels(&auditTestModel{})(&cfg)
+	type auditTestModel struct{ Model }
+	if cfg.Models[reflect.TypeOf(auditTestModel{})] {
+		t.Fatal("同名的不同类型不应匹配")
+	}
+
+	buf := &bytes.Buffer{}
+	ctx := uslog.Set(context.Background(), slog.New(slog.NewTextHandler(buf, nil)))
+	done := make(chan error, 1)
+	go func() {
+		done <- ZincAuditSink{Config: &zinc.Config{}}.Write(ctx, []AuditLog{{Action: AuditCreate}})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("丢弃时不应返回错误: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ZincAuditSink 不应阻塞")
+	}
+	if !strings.Contains(buf.String(), "audit zinc buffer full") || !strings.Contains(buf.String(), "dropped=1") {
+		t.Fatalf("丢弃时应记录警告: %s", buf.String())
+	}
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type BulkConfig struct {
+	BatchSize       int
+	ConflictColumns []string
+	UpdateColumns   []string
+	DoNothing       bool
+	DisableCopy     bool
+}
+
+type BulkOption func(*BulkConfig)
+
+// WithBulkBatchSize 设置每批写入的行数。
+func WithBulkBatchSize(size int) BulkOption {
+	return func(cfg *BulkConfig) {
+		if size > 0 {
+			cfg.BatchSize = size
+		}
+	}
+}
+
+// WithBulkOnConflict 冲突时更新已有记录，与 GenID 相同使用 ON CONFLICT 实现（MySQL 为 ON DUPLICATE KEY UPDATE）。
+// update 为空时更新除冲突列外的全部字段。
+func WithBulkOnConflict(columns []string, update ...string) BulkOption {
+	return func(cfg *BulkConfig) {
+		cfg.ConflictColumns = columns
+		cfg.UpdateColumns = update
+		cfg.DoNothing = false
+	}
+}
+
+// WithBulkSkipConflict 冲突时忽略该行。
+func WithBulkSkipConflict(columns ...string) BulkOption {
+	return func(cfg *BulkConfig) {
+		cfg.ConflictColumns = columns
+		cfg.DoNothing = true
+	}
+}
+
+// WithBulkDisableCopy 在 Postgres 上也使用多行 INSERT 而不是 COPY。
+func WithBulkDisableCopy() BulkOption {
+	return func(cfg *BulkConfig) {
+		cfg.DisableCopy = true
+	}
+}
+
+// BulkRowError 记录写入失败的行在输入中的下标。
+type BulkRowError struct {
+	Index int
+	Err   error
+}
+
+// BulkResult 为写入结果，Affected 为数据库返回的影响行数之和，
+// MySQL 的 ON DUPLICATE KEY UPDATE 中被更新的行计为 2、未变化的行计为 0。
+type BulkResult struct {
+	Affected int64
+	Failed   []BulkRowError
+}
+
+// BulkInsert 分批写入大量数据。
+// Postgres 上不需要处理冲突、不在事务中且没有依赖回调的插件（租户、审计、加载缓存）时使用 COPY，
+// 其余情况使用多行 INSERT。
+// 每批在独立的事务（已在事务中时为保存点）中执行，某批失败时逐行重试以找出失败的行，
+// 其余行照常写入，失败行记录在 BulkResult.Failed 中。
+// 使用 COPY 时不会回填自增主键，也不会执行 gorm 钩子。
+func BulkInsert[T any](ctx context.Context, objs []T, ops ...BulkOption) (*BulkResult, error) {
+	cfg := BulkConfig{
+		BatchSize: 1000,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+
+	db, err := TryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSchema(db, new(T))
+	if err != nil {
+		return nil, err
+	}
+
+	var clauses []clause.Expression
+	if len(cfg.ConflictColumns) > 0 {
+		oc := clause.OnConflict{DoNothing: cfg.DoNothing}
+		for _, c := range cfg.ConflictColumns {
+			oc.Columns = append(oc.Columns, clause.Column{Name: c})
+		}
+		if !cfg.DoNothing {
+			if len(cfg.UpdateColumns) > 0 {
+				oc.DoUpdates = clause.AssignmentColumns(cfg.UpdateColumns)
+			} else {
+				oc.UpdateAll = true
+			}
+		}
+		clauses = append(clauses, oc)
+	}
+
+	useCopy := db.Dialector.Name() == "postgres" && !cfg.DisableCopy &&
+		len(clauses) == 0 && !InTx(ctx) && copyable(s) && !needCallbacks(ctx, db)
+
+	rs := &BulkResult{}
+	for from := 0; from < len(objs); from += cfg.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return rs, err
+		}
+		batch := objs[from:min(from+cfg.BatchSize, len(objs))]
+
+		var n int64
+		if useCopy {
+			n, err = bulkCopy(ctx, db, s, batch)
+		} else {
+			err = Tx(ctx, func(ctx context.Context) error {
+				r := For(ctx).Clauses(clauses...).CreateInBatches(batch, len(batch))
+				n = r.RowsAffected
+				return r.Error
+			})
+		}
+		if err == nil {
+			rs.Affected += n
+			continue
+		}
+		if !isRowError(ctx, err) {
+			return rs, err
+		}
+
+		// 整批回滚后 gorm 可能已回填了自增主键，逐行重试前需要还原
+		if pk := s.PrioritizedPrimaryField; pk != nil && pk.AutoIncrement {
+			for i := range batch {
+				if err := pk.Set(ctx, reflect.ValueOf(&batch[i]).Elem(), reflect.Zero(pk.FieldType).Interface()); err != nil {
+					return rs, err
+				}
+			}
+		}
+
+		for i := range batch {
+			err := Tx(ctx, func(ctx context.Context) error {
+				r := For(ctx).Clauses(clauses...).Create(&batch[i])
+				n = r.RowsAffected
+				return r.Error
+			})
+			if err != nil {
+				if !isRowError(ctx, err) {
+					return rs, err
+				}
+				rs.Failed = append(rs.Failed, BulkRowError{Index: from + i, Err: err})
+				continue
+			}
+			rs.Affected += n
+		}
+	}
+	return rs, nil
+}
+
+// isRowError 判断错误是否由行数据本身引起（约束冲突、数据不合法等），
+// ctx 取消、连接断开等错误与行无关，应直接返回而不是记录为失败行。
+func isRowError(ctx context.Context, err error) bool {
+	return ctx.Err() == nil &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, driver.ErrBadConn) &&
+		!errors.Is(err, sql.ErrConnDone) &&
+		!errors.Is(err, sql.ErrTxDone)
+}
+
+// needCallbacks 判断写入是否依赖 gorm 回调：ctx 中有租户，或注册了租户、审计、加载缓存插件时，
+// COPY 会绕过租户列填充与租户 schema、审计日志和缓存失效，不能使用。
+func needCallbacks(ctx context.Context, db *gorm.DB) bool {
+	if _, ok := TenantFrom(ctx); ok {
+		return true
+	}
+	for _, name := range []string{"nzlov:tenant", "nzlov:audit", "nzlov:loader_cache"} {
+		if _, ok := db.Config.Plugins[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copyable 判断模型能否直接用 COPY 写入，使用自定义序列化的字段需要经过 gorm 处理，不能直接写入。
+func copyable(s *schema.Schema) bool {
+	for _, f := range s.Fields {
+		if f.DBName != "" && f.Serializer != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func bulkCopy[T any](ctx context.Context, db *gorm.DB, s *schema.Schema, objs []T) (int64, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0, err
+	}
+
+	fields := []*schema.Field{}
+	columns := []string{}
+	for _, f := range s.Fields {
+		if f.DBName == "" || !f.Creatable || (f.PrimaryKey && f.AutoIncrement) {
+			continue
+		}
+		fields = append(fields, f)
+		columns = append(columns, f.DBName)
+	}
+
+	now := time.Now()
+	rows := make([][]any, 0, len(objs))
+	for i := range objs {
+		rv := reflect.ValueOf(&objs[i]).Elem()
+		row := make([]any, len(fields))
+		for j, f := range fields {
+			v, zero := f.ValueOf(ctx, rv)
+			if zero && (f.AutoCreateTime > 0 || f.AutoUpdateTime > 0) {
+				if err := f.Set(ctx, rv, now); err != nil {
+					return 0, err
+				}
+				v, _ = f.ValueOf(ctx, rv)
+			}
+			if vr, ok := v.(driver.Valuer); ok {
+				if v, err = vr.Value(); err != nil {
+					return 0, fmt.Errorf("%s: %w", f.Name, err)
+				}
+			}
+			row[j] = v
+		}
+		rows = append(rows, row)
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var n int64
+	err = conn.Raw(func(dc any) error {
+		c, ok := dc.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("copy not supported by %T", dc)
+		}
+		n, err = c.Conn().CopyFrom(ctx, pgx.Identifier{s.Table}, columns, pgx.CopyFromRows(rows))
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type bulkTestModel struct {
+	Model
+	Code string `gorm:"uniqueIndex"`
+	Num  int
+}
+
+// TestBulkInsert 验证整批失败时逐行重试，只有真正冲突的行被记录为失败，其余行正常写入。
+func TestBulkInsert(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&bulkTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	ctx := testCtx(db)
+
+	objs := []bulkTestModel{}
+	for i := 0; i < 10; i++ {
+		objs = append(objs, bulkTestModel{Code: string(rune('a' + i)), Num: i})
+	}
+	objs[6].Code = "b"
+
+	rs, err := BulkInsert(ctx, objs, WithBulkBatchSize(4))
+	if err != nil {
+		t.Fatalf("批量写入失败: %v", err)
+	}
+	if rs.Affected != 9 || len(rs.Failed) != 1 || rs.Failed[0].Index != 6 {
+		t.Fatalf("写入结果不正确: %+v", rs)
+	}
+
+	count := int64(0)
+	db.Model(&bulkTestModel{}).Count(&count)
+	if count != 9 {
+		t.Fatalf("写入行数不正确: %d", count)
+	}
+}
+
+// TestBulkUpsert 验证冲突列上的更新只修改指定字段。
+func TestBulkUpsert(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&bulkTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	ctx := testCtx(db)
+
+	if _, err := BulkInsert(ctx, []bulkTestModel{{Code: "a", Num: 1}, {Code: "b", Num: 2}}); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	rs, err := BulkInsert(ctx, []bulkTestModel{{Code: "a", Num: 10}, {Code: "c", Num: 3}},
+		WithBulkOnConflict([]string{"code"}, "num"))
+	if err != nil || len(rs.Failed) != 0 {
+		t.Fatalf("upsert 失败: %+v %v", rs, err)
+	}
+
+	objs := []bulkTestModel{}
+	db.Order("code").Find(&objs)
+	if len(objs) != 3 || objs[0].Num != 10 || objs[2].Num != 3 {
+		t.Fatalf("upsert 结果不正确: %+v", objs)
+	}
+
+	rs, err = BulkInsert(ctx, []bulkTestModel{{Code: "a", Num: 100}}, WithBulkSkipConflict("code"))
+	if err != nil || len(rs.Failed) != 0 {
+		t.Fatalf("忽略冲突失败: %+v %v", rs, err)
+	}
+	db.Where("code = ?", "a").First(&objs[0])
+	if objs[0].Num != 10 {
+		t.Fatalf("忽略冲突时不应更新: %+v", objs[0])
+	}
+}
+
+// TestBulkInsertFatalError 确认连接错误直接返回，而不是把所有行记录为失败。
+func TestBulkInsertFatalError(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&bulkTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	db.Callback().Create().Before("gorm:create").Register("test:bad_conn", func(tx *gorm.DB) {
+		tx.AddError(driver.ErrBadConn)
+	})
+
+	rs, err := BulkInsert(testCtx(db), []bulkTestModel{{Code: "a"}, {Code: "b"}})
+	if !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("应返回连接错误: %v", err)
+	}
+	if len(rs.Failed) != 0 {
+		t.Fatalf("连接错误不应记录为失败行: %+v", rs.Failed)
+	}
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nzlov/utils/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckpointStore 保存 DBAll 的断点，Load 在没有断点时返回 nil, nil。
+type CheckpointStore interface {
+	Load(ctx context.Context, name string) ([]byte, error)
+	Save(ctx context.Context, name string, data []byte) error
+	Delete(ctx context.Context, name string) error
+}
+
+type Checkpoint struct {
+	Model
+	Name string `gorm:"uniqueIndex"`
+	Data string
+}
+
+// DBCheckpointStore 将断点保存在 checkpoints 表中，DB 为空时使用 For(ctx)。
+// 使用前需要 AutoMigrate(&Checkpoint{})。
+type DBCheckpointStore struct {
+	DB *gorm.DB
+}
+
+func (s *DBCheckpointStore) db(ctx context.Context) (*gorm.DB, error) {
+	if s.DB != nil {
+		return s.DB.WithContext(ctx), nil
+	}
+	return TryFor(ctx)
+}
+
+func (s *DBCheckpointStore) Load(ctx context.Context, name string) ([]byte, error) {
+	db, err := s.db(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cp := Checkpoint{}
+	if err := db.Where("name = ?", name).First(&cp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []byte(cp.Data), nil
+}
+
+func (s *DBCheckpointStore) Save(ctx context.Context, name string, data []byte) error {
+	db, err := s.db(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "updated_at", "deleted_at"}),
+	}).Create(&Checkpoint{Name: name, Data: string(data)}).Error
+}
+
+func (s *DBCheckpointStore) Delete(ctx context.Context, name string) error {
+	db, err := s.db(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Where("name = ?", name).Delete(&Checkpoint{}).Error
+}
+
+// RedisCheckpointStore 将断点保存在 Redis 中，key 为 Prefix+name。
+type RedisCheckpointStore struct {
+	Config *redis.Config
+	Prefix string
+}
+
+func (s *RedisCheckpointStore) Load(ctx context.Context, name string) ([]byte, error) {
+	data, err := s.Config.Redis().Get(ctx, s.Prefix+name).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *RedisCheckpointStore) Save(ctx context.Context, name string, data []byte) error {
+	return s.Config.Redis().Set(ctx, s.Prefix+name, data, 0).Err()
+}
+
+func (s *RedisCheckpointStore) Delete(ctx context.Context, name string) error {
+	return s.Config.Redis().Del(ctx, s.Prefix+name).Err()
+}
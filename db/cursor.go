@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page 是游标分页的结果，Next/Prev 为空表示没有下一页/上一页。
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+// PageRequest 描述一次游标分页请求。
+// Sorts 可以是多列且升降序混合，主键会自动追加为最后一个排序键以保证顺序唯一；
+// 排序列不应包含 NULL，否则游标比较会漏数据。
+type PageRequest struct {
+	Cursor  string   `json:"cursor"`
+	Limit   int      `json:"limit"`
+	Sorts   []Sort   `json:"sorts"`
+	Filters []Filter `json:"filters"`
+	Count   bool     `json:"count"`
+}
+
+type cursor struct {
+	Sorts  string            `json:"s"`
+	Prev   bool              `json:"p,omitempty"`
+	Values []json.RawMessage `json:"v"`
+}
+
+func encodeCursor(secret []byte, c cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+func decodeCursor(secret []byte, token string) (cursor, error) {
+	c := cursor{}
+	data, sign, ok := strings.Cut(token, ".")
+	if !ok {
+		return c, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sign)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)[:16]) {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Paginate 按游标分页查询，secret 用于签名游标，防止客户端篡改。
+// 游标中记录了排序键的取值，翻页条件展开为 OR-of-AND 形式，因此 postgres、mysql、sqlite 都可以使用。
+// 与 Repository 相同，会附加 WhereCtx 与 FilterCtx 设置的上下文条件，并且不包含软删除记录。
+func Paginate[T any](ctx context.Context, secret []byte, req PageRequest) (*Page[T], error) {
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	db, err := TryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseSchema(db, new(T))
+	if err != nil {
+		return nil, err
+	}
+	db = db.WithContext(ctx).Model(new(T))
+	if db, err = applyCtxWheres(ctx, db, s); err != nil {
+		return nil, err
+	}
+	for _, f := range req.Filters {
+		query, args, err := buildFilter(s, f)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(query, args...)
+	}
+
+	keys, sign, err := keysetKeys(s, req.Sorts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page[T]{}
+	if req.Count {
+		total := int64(0)
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	prev := false
+	if req.Cursor != "" {
+		c, err := decodeCursor(secret, req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sorts != sign {
+			return nil, fmt.Errorf("%w: sort mismatch", ErrInvalidCursor)
+		}
+		prev = c.Prev
+
+		values, err := keysetDecode(keys, c.Values)
+		if err != nil {
+			return nil, err
+		}
+		db = keysetWhere(db, s, keys, values, prev)
+	}
+
+	db = keysetOrder(db, s, keys, prev)
+	objs := []T{}
+	if err := db.Limit(req.Limit + 1).Find(&objs).Error; err != nil {
+		return nil, err
+	}
+	more := len(objs) > req.Limit
+	if more {
+		objs = objs[:req.Limit]
+	}
+	if prev {
+		slices.Reverse(objs)
+	}
+	page.Items = objs
+	if len(objs) == 0 {
+		return page, nil
+	}
+
+	makeCursor := func(obj *T, prev bool) (string, error) {
+		values, err := keysetEncode(ctx, keys, obj)
+		if err != nil {
+			return "", err
+		}
+		return encodeCursor(secret, cursor{Sorts: sign, Prev: prev, Values: values})
+	}
+
+	// 向后翻页时多取到一条说明还有下一页，带游标说明一定有上一页；向前翻页时反之
+	if (!prev && more) || (prev && req.Cursor != "") {
+		if page.Next, err = makeCursor(&objs[len(objs)-1], false); err != nil {
+			return nil, err
+		}
+	}
+	if (prev && more) || (!prev && req.Cursor != "") {
+		if page.Prev, err = makeCursor(&objs[0], true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestPaginate 验证多列混合升降序时前后翻页既不漏数也不重复，且游标无法被篡改。
+func TestPaginate(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&repoTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	ctx := testCtx(db)
+	for i := 0; i < 25; i++ {
+		if err := db.Create(&repoTestModel{Name: "n", Age: i % 4}).Error; err != nil {
+			t.Fatalf("写入测试数据失败: %v", err)
+		}
+	}
+
+	secret := []byte("secret")
+	req := PageRequest{Limit: 10, Sorts: []Sort{{Field: "Age", Desc: true}}, Count: true}
+
+	var pages []*Page[repoTestModel]
+	seen := map[uint]bool{}
+	for {
+		page, err := Paginate[repoTestModel](ctx, secret, req)
+		if err != nil {
+			t.Fatalf("分页查询失败: %v", err)
+		}
+		if *page.Total != 25 {
+			t.Fatalf("总数不正确: %d", *page.Total)
+		}
+		for i, v := range page.Items {
+			if seen[v.ID] {
+				t.Fatalf("记录重复: id=%d", v.ID)
+			}
+			seen[v.ID] = true
+			if i > 0 {
+				p := page.Items[i-1]
+				if p.Age < v.Age || (p.Age == v.Age && p.ID > v.ID) {
+					t.Fatalf("排序不正确: %+v %+v", p, v)
+				}
+			}
+		}
+		pages = append(pages, page)
+		if page.Next == "" {
+			break
+		}
+		req.Cursor = page.Next
+	}
+	if len(seen) != 25 || len(pages) != 3 {
+		t.Fatalf("分页结果不正确: records=%d pages=%d", len(seen), len(pages))
+	}
+
+	req.Cursor = pages[2].Prev
+	back, err := Paginate[repoTestModel](ctx, secret, req)
+	if err != nil {
+		t.Fatalf("向前翻页失败: %v", err)
+	}
+	if len(back.Items) != 10 || back.Items[0].ID != pages[1].Items[0].ID || back.Prev == "" || back.Next == "" {
+		t.Fatalf("向前翻页结果不正确: %+v", back.Items)
+	}
+
+	if _, err := Paginate[repoTestModel](ctx, []byte("other"), req); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("篡改的游标应被拒绝: %v", err)
+	}
+}
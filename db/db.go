@@ -2,24 +2,54 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"embed"
 	"errors"
 	"fmt"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/glebarez/sqlite"
-	"golang.org/x/sync/errgroup"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var ErrNilContext = errors.New("nil context")
+var (
+	ErrNilContext = errors.New("nil context")
+	ErrNoDB       = errors.New("no db in context")
+)
+
+// NoDBError 记录上下文中取不到数据库时的调用栈，便于定位是哪一层丢失了 db 注入。
+type NoDBError struct {
+	Stack []string
+}
+
+func (e *NoDBError) Error() string {
+	return fmt.Sprintf("%s, called from:\n\t%s", ErrNoDB, strings.Join(e.Stack, "\n\t"))
+}
+
+func (e *NoDBError) Unwrap() error {
+	return ErrNoDB
+}
+
+func newNoDBError(skip int) *NoDBError {
+	var pcs [16]uintptr
+	n := runtime.Callers(skip+1, pcs[:])
+	fs := runtime.CallersFrames(pcs[:n])
+	e := &NoDBError{}
+	for {
+		f, more := fs.Next()
+		e.Stack = append(e.Stack, fmt.Sprintf("%s:%d %s", f.File, f.Line, f.Function))
+		if !more {
+			break
+		}
+	}
+	return e
+}
 
 type Model struct {
 	CreatedAt time.Time
@@ -46,28 +76,96 @@ type Config struct {
 }
 
 func (c *Config) DB() *gorm.DB {
-	if c.db != nil {
-		return c.db
-	}
-	db, err := c.Open()
+	db, err := c.TryDB()
 	if err != nil {
 		panic(err)
 	}
 	return db
 }
 
+// TryDB 与 DB 相同，但打开失败时返回错误而不是 panic。
+func (c *Config) TryDB(ops ...Option) (*gorm.DB, error) {
+	if c.db != nil {
+		return c.db, nil
+	}
+	return c.Open(ops...)
+}
+
 type ctxKey struct{}
 
 var _ctxKey = ctxKey{}
 
+var _defaultDB atomic.Pointer[gorm.DB]
+
+// SetDefault 注册默认数据库，上下文中没有注入 db 时 For/TryFor 会回退使用它。
+// 传入 nil 可取消默认数据库。
+func SetDefault(db *gorm.DB) {
+	_defaultDB.Store(db)
+}
+
+// SetDefault 打开数据库并注册为默认数据库。
+func (c *Config) SetDefault(ops ...Option) error {
+	db, err := c.TryDB(ops...)
+	if err != nil {
+		return err
+	}
+	SetDefault(db)
+	return nil
+}
+
+// For 取出上下文中的 db，没有时使用默认数据库。
+// 返回的是绑定了 ctx 的新会话（db.Session(&gorm.Session{Context: ctx})），
+// 回调与插件（租户、审计、追踪等）通过 Statement.Context 读取 ctx，不需要再调用 WithContext。
 func For(ctx context.Context) *gorm.DB {
-	return ctx.Value(_ctxKey).(*gorm.DB).Session(&gorm.Session{})
+	db, err := tryFor(ctx, 3)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// TryFor 与 For 相同，但上下文中没有 db 且未注册默认数据库时返回 *NoDBError，
+// 其中带有调用栈，可用 errors.Is(err, ErrNoDB) 判断。
+func TryFor(ctx context.Context) (*gorm.DB, error) {
+	return tryFor(ctx, 3)
+}
+
+func tryFor(ctx context.Context, skip int) (*gorm.DB, error) {
+	if ctx == nil {
+		return nil, ErrNilContext
+	}
+	db, ok := ctx.Value(_ctxKey).(*gorm.DB)
+	if !ok || db == nil {
+		db = _defaultDB.Load()
+	}
+	if db == nil {
+		return nil, newNoDBError(skip)
+	}
+	return db.Session(&gorm.Session{Context: ctx}), nil
+}
+
+// HasDB 判断上下文中是否注入了 db，不考虑默认数据库。
+func HasDB(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	db, ok := ctx.Value(_ctxKey).(*gorm.DB)
+	return ok && db != nil
 }
 
 func (c *Config) Ctx(ctx context.Context) context.Context {
 	return context.WithValue(ctx, _ctxKey, c.DB())
 }
 
+// TryCtx 与 Ctx 相同，但打开数据库失败时返回错误。
+func (c *Config) TryCtx(ctx context.Context) (context.Context, error) {
+	db, err := c.TryDB()
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, _ctxKey, db), nil
+}
+
 func Ctx(ctx context.Context, db *gorm.DB) context.Context {
 	return context.WithValue(ctx, _ctxKey, db)
 }
@@ -79,12 +177,6 @@ func CtxNew(ctx context.Context) context.Context {
 	}))
 }
 
-func Tx(ctx context.Context, f func(context.Context) error, opts ...*sql.TxOptions) error {
-	return For(ctx).Transaction(func(tx *gorm.DB) error {
-		return f(Ctx(ctx, tx))
-	}, opts...)
-}
-
 type Option func(*gorm.Config)
 
 func NewLoggerOp(logger logger.Interface) func(*gorm.Config) {
@@ -121,7 +213,10 @@ func (d *Config) Open(ops ...Option) (*gorm.DB, error) {
 // ExecuteSQLFilesFromEmbed reads SQL files from an embedded directory and executes them if not already executed.
 // Uses a transaction to ensure atomicity of SQL execution and history recording.
 func ExecuteSQLFilesFromEmbed(ctx context.Context, fs embed.FS, dir string) error {
-	db := For(ctx)
+	db, err := TryFor(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Auto-migrate the SQL execution history table
 	if err := db.AutoMigrate(&SQLExecutionHistory{}); err != nil {
@@ -183,103 +278,3 @@ func ExecuteSQLFilesFromEmbed(ctx context.Context, fs embed.FS, dir string) erro
 type DBModelID interface {
 	GetID() uint
 }
-
-type DBAllConfig struct {
-	PageSize    int
-	Concurrency int
-}
-
-type DBAllOption func(*DBAllConfig)
-
-// WithDBAllPageSize 允许按需调整分页大小，避免不同表规模下固定分页带来额外压力。
-func WithDBAllPageSize(size int) DBAllOption {
-	return func(cfg *DBAllConfig) {
-		if size > 0 {
-			cfg.PageSize = size
-		}
-	}
-}
-
-// WithDBAllConcurrency 允许按需调整回调并发数，兼顾吞吐和下游处理能力。
-// channel 缓冲区会与并发数保持一致，避免生产速度远高于消费速度时积压过多内存。
-func WithDBAllConcurrency(concurrency int) DBAllOption {
-	return func(cfg *DBAllConfig) {
-		if concurrency > 0 {
-			cfg.Concurrency = concurrency
-		}
-	}
-}
-
-// DBAll 按主键递增分页读取数据，并将结果分发给固定数量的工作协程并发执行回调。
-// 通过透传 context 可以让外部取消信号和内部错误更快地停止后续分页与回调处理。
-func DBAll[T DBModelID](ctx context.Context, cb func(context.Context, T) error, ops ...DBAllOption) error {
-	defaultConcurrency := runtime.NumCPU() * 2
-	if defaultConcurrency <= 0 {
-		defaultConcurrency = 1
-	}
-
-	cfg := DBAllConfig{
-		PageSize:    100,
-		Concurrency: defaultConcurrency,
-	}
-	for _, op := range ops {
-		op(&cfg)
-	}
-
-	if ctx == nil {
-		return ErrNilContext
-	}
-
-	db := For(ctx)
-	g, ctx := errgroup.WithContext(ctx)
-	lastid := uint(0)
-	ch := make(chan T, cfg.Concurrency)
-
-	for i := 0; i < cfg.Concurrency; i++ {
-		g.Go(func() error {
-			for v := range ch {
-				if err := cb(ctx, v); err != nil {
-					return err
-				}
-			}
-			return nil
-		})
-	}
-
-	g.Go(func() error {
-		defer close(ch)
-
-		for {
-			if err := ctx.Err(); err != nil {
-				return err
-			}
-
-			objs := make([]T, 0, cfg.PageSize)
-			if err := db.Where("id > ?", lastid).Order("id").Limit(cfg.PageSize).Find(&objs).Error; err != nil {
-				return err
-			}
-			if len(objs) == 0 {
-				return nil
-			}
-
-			for _, v := range objs {
-				lastid = v.GetID()
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case ch <- v:
-				}
-			}
-
-			if len(objs) != cfg.PageSize {
-				return nil
-			}
-		}
-	})
-
-	if err := g.Wait(); err != nil {
-		return err
-	}
-
-	return nil
-}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -19,11 +20,15 @@ type dbAllTestModel struct {
 }
 
 // openTestDB 使用共享内存数据库隔离测试数据，避免文件状态影响分页与并发断言。
-func openTestDB(t *testing.T) *gorm.DB {
+func openTestDB(t *testing.T, ops ...Option) *gorm.DB {
 	t.Helper()
 
+	cfg := gorm.Config{}
+	for _, op := range ops {
+		op(&cfg)
+	}
 	dbName := fmt.Sprintf("file:dball_%d?mode=memory&cache=shared", time.Now().UnixNano())
-	db, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(dbName), &cfg)
 	if err != nil {
 		t.Fatalf("打开测试数据库失败: %v", err)
 	}
@@ -168,3 +173,177 @@ func TestDBAllNilContext(t *testing.T) {
 		t.Fatalf("返回错误不正确: got=%v want=%v", err, ErrNilContext)
 	}
 }
+
+// TestTryForNoDB 确认未注入 db 时返回带调用栈的错误而不是 panic，方便定位丢失注入的位置。
+func TestTryForNoDB(t *testing.T) {
+	_, err := TryFor(context.Background())
+	if !errors.Is(err, ErrNoDB) {
+		t.Fatalf("返回错误不正确: got=%v want=%v", err, ErrNoDB)
+	}
+	var nerr *NoDBError
+	if !errors.As(err, &nerr) || len(nerr.Stack) == 0 {
+		t.Fatalf("错误中缺少调用栈: %v", err)
+	}
+	if !strings.Contains(nerr.Stack[0], "TestTryForNoDB") {
+		t.Fatalf("调用栈首帧应为调用方: %s", nerr.Stack[0])
+	}
+}
+
+// TestTryForDefault 验证注册默认数据库后，未注入 db 的上下文可以回退使用默认库。
+func TestTryForDefault(t *testing.T) {
+	db := openTestDB(t)
+	SetDefault(db)
+	defer SetDefault(nil)
+
+	got, err := TryFor(context.Background())
+	if err != nil {
+		t.Fatalf("获取默认数据库失败: %v", err)
+	}
+	if got.Statement.ConnPool != db.Statement.ConnPool {
+		t.Fatalf("未使用默认数据库")
+	}
+	if HasDB(context.Background()) {
+		t.Fatalf("HasDB 不应把默认数据库视为已注入")
+	}
+}
+
+// TestDBAllCheckpoint 验证中断后重跑会从最后确认的页之后继续，完成后清理断点。
+func TestDBAllCheckpoint(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&Checkpoint{}); err != nil {
+		t.Fatalf("迁移断点表失败: %v", err)
+	}
+	seedTestModels(t, db, 100)
+	ctx := testCtx(db)
+	store := &DBCheckpointStore{}
+
+	wantErr := errors.New("callback failed")
+	err := DBAll(ctx, func(ctx context.Context, item dbAllTestModel) error {
+		if item.ID == 55 {
+			return wantErr
+		}
+		return nil
+	}, WithDBAllPageSize(10), WithDBAllConcurrency(1), WithDBAllCheckpoint(store, "backfill"))
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("返回错误不正确: got=%v want=%v", err, wantErr)
+	}
+
+	first := uint(0)
+	err = DBAll(ctx, func(ctx context.Context, item dbAllTestModel) error {
+		if first == 0 {
+			first = item.ID
+		}
+		return nil
+	}, WithDBAllPageSize(10), WithDBAllConcurrency(1), WithDBAllCheckpoint(store, "backfill"))
+	if err != nil {
+		t.Fatalf("续跑失败: %v", err)
+	}
+	if first != 51 {
+		t.Fatalf("续跑起点不正确: got=%d want=51", first)
+	}
+	if data, err := store.Load(ctx, "backfill"); err != nil || data != nil {
+		t.Fatalf("完成后应删除断点: data=%s err=%v", data, err)
+	}
+}
+
+// TestDBAllOrderAndScopes 验证自定义排序键、过滤条件与上下文条件同时生效。
+func TestDBAllOrderAndScopes(t *testing.T) {
+	db := openTestDB(t)
+	seedTestModels(t, db, 30)
+	ctx := WhereCtx(testCtx(db), "id <= ?", 20)
+
+	var ids []uint
+	err := DBAll(ctx, func(ctx context.Context, item dbAllTestModel) error {
+		ids = append(ids, item.ID)
+		return nil
+	}, WithDBAllPageSize(3), WithDBAllConcurrency(1),
+		WithDBAllOrder(Sort{Field: "ID", Desc: true}),
+		WithDBAllScopes(func(db *gorm.DB) *gorm.DB { return db.Where("id % 2 = 0") }))
+	if err != nil {
+		t.Fatalf("遍历数据失败: %v", err)
+	}
+	if len(ids) != 10 || ids[0] != 20 || ids[9] != 2 {
+		t.Fatalf("遍历结果不正确: %v", ids)
+	}
+}
+
+// TestDBAllBatchContinueOnError 验证批量回调的批次大小，以及出错后继续处理并在结束时汇总失败记录。
+func TestDBAllBatchContinueOnError(t *testing.T) {
+	db := openTestDB(t)
+	seedTestModels(t, db, 25)
+
+	wantErr := errors.New("batch failed")
+	var mu sync.Mutex
+	sizes := map[int]int{}
+	err := DBAllBatch(testCtx(db), func(ctx context.Context, items []dbAllTestModel) error {
+		mu.Lock()
+		sizes[len(items)]++
+		mu.Unlock()
+		if items[0].ID == 11 {
+			return wantErr
+		}
+		return nil
+	}, WithDBAllPageSize(10), WithDBAllBatchSize(5), WithDBAllConcurrency(2),
+		WithDBAllContinueOnError(0), WithDBAllRateLimit(1000, 5), WithDBAllMetrics("test"))
+
+	var report *DBAllError
+	if !errors.As(err, &report) || !errors.Is(err, wantErr) {
+		t.Fatalf("返回错误不正确: %v", err)
+	}
+	if report.Processed != 25 || report.Failed != 5 || len(report.Errors[0].IDs) != 5 {
+		t.Fatalf("汇总结果不正确: %+v", report)
+	}
+	if sizes[5] != 5 {
+		t.Fatalf("批次大小不正确: %v", sizes)
+	}
+}
+
+// TestDBAllPartitions 验证按主键分区并发读取时每条记录恰好处理一次，且分区不能与断点同时使用。
+func TestDBAllPartitions(t *testing.T) {
+	db := openTestDB(t)
+	seedTestModels(t, db, 103)
+
+	seen := make(map[uint]int, 103)
+	var mu sync.Mutex
+	err := DBAll(testCtx(db), func(ctx context.Context, item dbAllTestModel) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[item.ID]++
+		return nil
+	}, WithDBAllPageSize(7), WithDBAllConcurrency(3), WithDBAllPartitions(4))
+	if err != nil {
+		t.Fatalf("分区遍历失败: %v", err)
+	}
+	if len(seen) != 103 {
+		t.Fatalf("处理记录数不正确: got=%d want=103", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Fatalf("记录处理次数异常: id=%d count=%d", id, n)
+		}
+	}
+
+	err = DBAll(testCtx(db), func(ctx context.Context, item dbAllTestModel) error {
+		return nil
+	}, WithDBAllPartitions(2), WithDBAllCheckpoint(&DBCheckpointStore{}, "x"))
+	if !errors.Is(err, ErrDBAllPartitionCheckpoint) {
+		t.Fatalf("返回错误不正确: %v", err)
+	}
+}
+
+// TestDBAllOptionsNotMutated 确认 DBAll 追加参数时不会写入调用方切片的剩余容量。
+func TestDBAllOptionsNotMutated(t *testing.T) {
+	db := openTestDB(t)
+	seedTestModels(t, db, 3)
+
+	ops := make([]DBAllOption, 1, 2)
+	ops[0] = WithDBAllPageSize(2)
+	if err := DBAll(testCtx(db), func(ctx context.Context, item dbAllTestModel) error {
+		return nil
+	}, ops...); err != nil {
+		t.Fatalf("遍历数据失败: %v", err)
+	}
+	if ops[:2][1] != nil {
+		t.Fatal("调用方的参数切片被修改")
+	}
+}
@@ -0,0 +1,489 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+
+	uotel "github.com/nzlov/utils/otel"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+var ErrDBAllPartitionCheckpoint = errors.New("db all partitions can not be used with checkpoint")
+
+type DBAllConfig struct {
+	PageSize    int
+	Concurrency int
+
+	Scopes         []func(*gorm.DB) *gorm.DB
+	Sorts          []Sort
+	Checkpoint     CheckpointStore
+	CheckpointName string
+
+	BatchSize       int
+	RateLimit       rate.Limit
+	RateBurst       int
+	ContinueOnError bool
+	MaxErrors       int
+	MetricsName     string
+	Partitions      int
+}
+
+type DBAllOption func(*DBAllConfig)
+
+// WithDBAllPageSize 允许按需调整分页大小，避免不同表规模下固定分页带来额外压力。
+func WithDBAllPageSize(size int) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		if size > 0 {
+			cfg.PageSize = size
+		}
+	}
+}
+
+// WithDBAllConcurrency 允许按需调整回调并发数，兼顾吞吐和下游处理能力。
+// channel 缓冲区会与并发数保持一致，避免生产速度远高于消费速度时积压过多内存。
+func WithDBAllConcurrency(concurrency int) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		if concurrency > 0 {
+			cfg.Concurrency = concurrency
+		}
+	}
+}
+
+// WithDBAllScopes 追加过滤条件，只遍历满足条件的数据。
+func WithDBAllScopes(scopes ...func(*gorm.DB) *gorm.DB) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		cfg.Scopes = append(cfg.Scopes, scopes...)
+	}
+}
+
+// WithDBAllOrder 使用自定义的排序键分页，例如按 updated_at 遍历，主键会自动追加为最后一个键。
+// 排序键在遍历期间应单调变化且不为 NULL，否则可能漏掉或重复处理被修改的记录。
+func WithDBAllOrder(sorts ...Sort) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		cfg.Sorts = sorts
+	}
+}
+
+// WithDBAllCheckpoint 开启断点续跑，name 用于区分不同任务。
+// 只有某一页及其之前的全部数据都回调成功后才会保存断点，因此中断后重跑最多重复处理未确认的几页；
+// 全部完成后断点会被删除，下次从头开始。
+func WithDBAllCheckpoint(store CheckpointStore, name string) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		cfg.Checkpoint = store
+		cfg.CheckpointName = name
+	}
+}
+
+// WithDBAllBatchSize 设置 DBAllBatch 每次回调的数据条数，批次不会跨页，
+// 大于分页大小时分页大小会随之调整。
+func WithDBAllBatchSize(size int) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		if size > 0 {
+			cfg.BatchSize = size
+		}
+	}
+}
+
+// WithDBAllRateLimit 限制每秒回调处理的数据条数，burst 为允许的突发条数，
+// 批量模式下 burst 至少为批次大小。
+func WithDBAllRateLimit(perSecond float64, burst int) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		if perSecond > 0 {
+			cfg.RateLimit = rate.Limit(perSecond)
+			cfg.RateBurst = max(burst, 1)
+		}
+	}
+}
+
+// WithDBAllContinueOnError 回调出错时记录错误并继续处理后续数据，结束后以 *DBAllError 返回全部失败记录。
+// maxErrors 大于 0 时失败次数超过该值会立即终止，0 表示不限制。
+// 开启断点时失败的数据同样视为已处理，断点会越过它们，需要根据报告单独补偿。
+func WithDBAllContinueOnError(maxErrors int) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		cfg.ContinueOnError = true
+		cfg.MaxErrors = maxErrors
+	}
+}
+
+// WithDBAllMetrics 通过 otel 上报处理进度，name 作为 task 属性区分不同任务。
+// 上报 db.all.items（按 status=ok/failed 区分）与 db.all.pages 两个计数器。
+func WithDBAllMetrics(name string) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		cfg.MetricsName = name
+	}
+}
+
+// WithDBAllPartitions 按主键 MIN/MAX 将数据均分为 n 个区间，由 n 个生产者并发分页读取，
+// 适合单个生产者顺序分页成为瓶颈的大表。区间内仍按排序键读取，但不同区间之间不保证顺序。
+// 区间边界在开始时确定，不能与断点续跑同时使用。
+func WithDBAllPartitions(n int) DBAllOption {
+	return func(cfg *DBAllConfig) {
+		if n > 0 {
+			cfg.Partitions = n
+		}
+	}
+}
+
+// dbAllRanges 查询过滤后的主键范围并均分为至多 n 个左闭右开区间。
+func dbAllRanges(db *gorm.DB, pk string, n int) ([][2]uint, error) {
+	var lo, hi sql.NullInt64
+	if err := db.Session(&gorm.Session{}).Select("MIN("+pk+"), MAX("+pk+")").Row().Scan(&lo, &hi); err != nil {
+		return nil, err
+	}
+	if !lo.Valid || !hi.Valid {
+		return nil, nil
+	}
+	total := uint(hi.Int64-lo.Int64) + 1
+	span := max((total+uint(n)-1)/uint(n), 1)
+	ranges := make([][2]uint, 0, n)
+	for from := uint(lo.Int64); from <= uint(hi.Int64); from += span {
+		ranges = append(ranges, [2]uint{from, min(from+span, uint(hi.Int64)+1)})
+	}
+	return ranges, nil
+}
+
+// DBAllItemError 记录一次失败的回调，批量模式下 IDs 为整批数据的主键。
+type DBAllItemError struct {
+	IDs []uint
+	Err error
+}
+
+// DBAllError 是开启 WithDBAllContinueOnError 后返回的汇总错误。
+type DBAllError struct {
+	Processed int64
+	Failed    int64
+	Errors    []DBAllItemError
+}
+
+func (e *DBAllError) Error() string {
+	return fmt.Sprintf("db all: %d of %d items failed, first: %v", e.Failed, e.Processed, e.Errors[0].Err)
+}
+
+func (e *DBAllError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, v := range e.Errors {
+		errs = append(errs, v.Err)
+	}
+	return errs
+}
+
+type dbAllMetrics struct {
+	items metric.Int64Counter
+	pages metric.Int64Counter
+	task  attribute.KeyValue
+}
+
+func newDBAllMetrics(name string) (*dbAllMetrics, error) {
+	meter := uotel.Meter
+	if meter == nil {
+		meter = otel.GetMeterProvider().Meter("github.com/nzlov/utils/db")
+	}
+	items, err := meter.Int64Counter("db.all.items", metric.WithDescription("DBAll 处理的数据条数"))
+	if err != nil {
+		return nil, err
+	}
+	pages, err := meter.Int64Counter("db.all.pages", metric.WithDescription("DBAll 读取的页数"))
+	if err != nil {
+		return nil, err
+	}
+	return &dbAllMetrics{items: items, pages: pages, task: attribute.String("task", name)}, nil
+}
+
+// dbAllPage 记录一页中尚未回调完成的数量，用于计算可以安全保存的断点。
+type dbAllPage struct {
+	remaining int
+	values    []json.RawMessage
+}
+
+type dbAllItem[T any] struct {
+	vs   []T
+	page *dbAllPage
+}
+
+type dbAllTracker struct {
+	mu    sync.Mutex
+	pages []*dbAllPage
+	store CheckpointStore
+	name  string
+	sorts string
+}
+
+func (t *dbAllTracker) add(p *dbAllPage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pages = append(t.pages, p)
+}
+
+// done 标记 n 条数据处理完成，若队首连续的页都已完成则保存其中最后一页的断点。
+func (t *dbAllTracker) done(ctx context.Context, p *dbAllPage, n int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p.remaining -= n
+
+	var last *dbAllPage
+	for len(t.pages) > 0 && t.pages[0].remaining == 0 {
+		last = t.pages[0]
+		t.pages = t.pages[1:]
+	}
+	if last == nil {
+		return nil
+	}
+	data, err := json.Marshal(cursor{Sorts: t.sorts, Values: last.values})
+	if err != nil {
+		return err
+	}
+	return t.store.Save(ctx, t.name, data)
+}
+
+// DBAll 按排序键递增分页读取数据（默认为主键），并将结果分发给固定数量的工作协程并发执行回调。
+// 通过透传 context 可以让外部取消信号和内部错误更快地停止后续分页与回调处理。
+// 查询会附加 WhereCtx 与 FilterCtx 设置的上下文条件以及 WithDBAllScopes 的过滤条件。
+func DBAll[T DBModelID](ctx context.Context, cb func(context.Context, T) error, ops ...DBAllOption) error {
+	return dbAll(ctx, func(ctx context.Context, vs []T) error {
+		return cb(ctx, vs[0])
+	}, append(slices.Clip(ops), WithDBAllBatchSize(1))...)
+}
+
+// DBAllBatch 与 DBAll 相同，但每次回调收到一批数据，批次大小由 WithDBAllBatchSize 设置，默认为分页大小。
+func DBAllBatch[T DBModelID](ctx context.Context, cb func(context.Context, []T) error, ops ...DBAllOption) error {
+	return dbAll(ctx, cb, ops...)
+}
+
+func dbAll[T DBModelID](ctx context.Context, cb func(context.Context, []T) error, ops ...DBAllOption) error {
+	defaultConcurrency := runtime.NumCPU() * 2
+	if defaultConcurrency <= 0 {
+		defaultConcurrency = 1
+	}
+
+	cfg := DBAllConfig{
+		PageSize:    100,
+		Concurrency: defaultConcurrency,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = cfg.PageSize
+	}
+	cfg.PageSize = max(cfg.PageSize, cfg.BatchSize)
+
+	db, err := TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := parseSchema(db, new(T))
+	if err != nil {
+		return err
+	}
+	keys, sign, err := keysetKeys(s, cfg.Sorts)
+	if err != nil {
+		return err
+	}
+
+	db = db.Model(new(T)).Scopes(cfg.Scopes...)
+	if db, err = applyCtxWheres(ctx, db, s); err != nil {
+		return err
+	}
+	// 生成新的会话，分区生产者各自追加条件时不会相互影响
+	db = db.Session(&gorm.Session{})
+
+	if cfg.Partitions > 1 && cfg.Checkpoint != nil {
+		return ErrDBAllPartitionCheckpoint
+	}
+
+	pk := s.Table + "." + s.PrioritizedPrimaryField.DBName
+	var ranges [][2]uint
+	if cfg.Partitions > 1 {
+		if ranges, err = dbAllRanges(db, pk, cfg.Partitions); err != nil {
+			return err
+		}
+		if len(ranges) == 0 {
+			return nil
+		}
+	}
+
+	var start []any
+	var tracker *dbAllTracker
+	if cfg.Checkpoint != nil {
+		tracker = &dbAllTracker{store: cfg.Checkpoint, name: cfg.CheckpointName, sorts: sign}
+		data, err := cfg.Checkpoint.Load(ctx, cfg.CheckpointName)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			c := cursor{}
+			if err := json.Unmarshal(data, &c); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+			}
+			if c.Sorts != sign {
+				return fmt.Errorf("%w: checkpoint %s sort mismatch", ErrInvalidCursor, cfg.CheckpointName)
+			}
+			if start, err = keysetDecode(keys, c.Values); err != nil {
+				return err
+			}
+		}
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RateLimit > 0 {
+		limiter = rate.NewLimiter(cfg.RateLimit, max(cfg.RateBurst, cfg.BatchSize))
+	}
+
+	var metrics *dbAllMetrics
+	if cfg.MetricsName != "" {
+		if metrics, err = newDBAllMetrics(cfg.MetricsName); err != nil {
+			return err
+		}
+	}
+
+	var mu sync.Mutex
+	report := &DBAllError{}
+
+	g, gctx := errgroup.WithContext(ctx)
+	ch := make(chan dbAllItem[T], cfg.Concurrency)
+
+	for i := 0; i < cfg.Concurrency; i++ {
+		g.Go(func() error {
+			for v := range ch {
+				if limiter != nil {
+					if err := limiter.WaitN(gctx, len(v.vs)); err != nil {
+						return err
+					}
+				}
+
+				err := cb(gctx, v.vs)
+				if metrics != nil {
+					status := attribute.String("status", "ok")
+					if err != nil {
+						status = attribute.String("status", "failed")
+					}
+					metrics.items.Add(gctx, int64(len(v.vs)), metric.WithAttributes(metrics.task, status))
+				}
+				if err != nil && !cfg.ContinueOnError {
+					return err
+				}
+
+				mu.Lock()
+				report.Processed += int64(len(v.vs))
+				if err != nil {
+					ids := make([]uint, 0, len(v.vs))
+					for _, obj := range v.vs {
+						ids = append(ids, obj.GetID())
+					}
+					report.Failed += int64(len(v.vs))
+					report.Errors = append(report.Errors, DBAllItemError{IDs: ids, Err: err})
+					if cfg.MaxErrors > 0 && len(report.Errors) > cfg.MaxErrors {
+						mu.Unlock()
+						return report
+					}
+				}
+				mu.Unlock()
+
+				if tracker != nil {
+					if err := tracker.done(gctx, v.page, len(v.vs)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	// produce 在 [lo, hi) 主键范围内按排序键分页读取，lo/hi 为 nil 表示不限制
+	produce := func(lo, hi *uint) error {
+		after := start
+		base := db
+		if lo != nil {
+			base = base.Where(pk+" >= ? AND "+pk+" < ?", *lo, *hi)
+		}
+		for {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+
+			q := base.Session(&gorm.Session{})
+			if after != nil {
+				q = keysetWhere(q, s, keys, after, false)
+			}
+			objs := make([]T, 0, cfg.PageSize)
+			if err := keysetOrder(q, s, keys, false).Limit(cfg.PageSize).Find(&objs).Error; err != nil {
+				return err
+			}
+			if len(objs) == 0 {
+				return nil
+			}
+			if metrics != nil {
+				metrics.pages.Add(gctx, 1, metric.WithAttributes(metrics.task))
+			}
+
+			last := &objs[len(objs)-1]
+			after = keysetValues(gctx, keys, last)
+			var page *dbAllPage
+			if tracker != nil {
+				values, err := keysetEncode(gctx, keys, last)
+				if err != nil {
+					return err
+				}
+				page = &dbAllPage{remaining: len(objs), values: values}
+				tracker.add(page)
+			}
+
+			for i := 0; i < len(objs); i += cfg.BatchSize {
+				select {
+				case <-gctx.Done():
+					return gctx.Err()
+				case ch <- dbAllItem[T]{vs: objs[i:min(i+cfg.BatchSize, len(objs))], page: page}:
+				}
+			}
+
+			if len(objs) != cfg.PageSize {
+				return nil
+			}
+		}
+	}
+
+	if cfg.Partitions <= 1 {
+		g.Go(func() error {
+			defer close(ch)
+			return produce(nil, nil)
+		})
+	} else {
+		var producers sync.WaitGroup
+		for _, r := range ranges {
+			producers.Add(1)
+			g.Go(func() error {
+				defer producers.Done()
+				return produce(&r[0], &r[1])
+			})
+		}
+		go func() {
+			producers.Wait()
+			close(ch)
+		}()
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	if cfg.Checkpoint != nil {
+		if err := cfg.Checkpoint.Delete(ctx, cfg.CheckpointName); err != nil {
+			return err
+		}
+	}
+	if len(report.Errors) > 0 {
+		return report
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Decimal 精确小数，适用于金额。以字符串与数据库交换数据，避免经过 float64 丢失精度：
+// Postgres 为 numeric，MySQL 为 decimal，SQLite 为 TEXT（numeric 亲和性会转为浮点数）。
+// 精度可通过 gorm:"precision:20;scale:4" 指定，MySQL 默认 decimal(38,10)。
+// 运算使用内嵌的 decimal.Decimal，结果可用 Decimal{d} 包装回来。
+type Decimal struct {
+	decimal.Decimal
+}
+
+func NewDecimal(d decimal.Decimal) Decimal {
+	return Decimal{d}
+}
+
+// ParseDecimal 从字符串解析，如 "12.34"。
+func ParseDecimal(s string) (Decimal, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{d}, nil
+}
+
+// DecimalFromMinor 由最小货币单位构造，如 DecimalFromMinor(1234, 2) 为 12.34。
+func DecimalFromMinor(v int64, scale int32) Decimal {
+	return Decimal{decimal.New(v, -scale)}
+}
+
+func (Decimal) GormDataType() string {
+	return "decimal"
+}
+
+func (Decimal) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		if field.Precision > 0 {
+			return fmt.Sprintf("numeric(%d,%d)", field.Precision, field.Scale)
+		}
+		return "numeric"
+	case "mysql":
+		if field.Precision > 0 {
+			return fmt.Sprintf("decimal(%d,%d)", field.Precision, field.Scale)
+		}
+		return "decimal(38,10)"
+	default:
+		return "TEXT"
+	}
+}
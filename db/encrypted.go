@@ -0,0 +1,141 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nzlov/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrNoEncryptionKey = errors.New("encryption key not found")
+	ErrInvalidCipher   = errors.New("invalid encrypted value")
+)
+
+type encryptionKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+var _encryptionKeys atomic.Pointer[encryptionKeys]
+
+// SetEncryptionKeys 设置 Encrypted 使用的密钥，keys 为 密钥 ID -> AES 密钥（16/24/32 字节）。
+// 写入时使用 current 对应的密钥，读取时按密文中记录的密钥 ID 选择密钥，
+// 轮换密钥时将新密钥设为 current 并保留旧密钥，旧数据可继续读取，重新保存后即使用新密钥。
+func SetEncryptionKeys(current string, keys map[string][]byte) error {
+	ks := &encryptionKeys{current: current, keys: map[string][]byte{}}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return fmt.Errorf("invalid encryption key id %q", id)
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return fmt.Errorf("invalid encryption key %s: length %d", id, len(key))
+		}
+		ks.keys[id] = key
+	}
+	if _, ok := ks.keys[current]; !ok {
+		return fmt.Errorf("%w: %s", ErrNoEncryptionKey, current)
+	}
+	_encryptionKeys.Store(ks)
+	return nil
+}
+
+// Encrypted 将 V 序列化为 JSON 后使用 AES-GCM 加密保存，格式为 密钥ID:base64(nonce+密文)。
+// 加密结果每次不同，不能用于等值查询或索引。JSON 序列化时输出明文 V。
+type Encrypted[T any] struct {
+	V T
+
+	key string // 读取时使用的密钥 ID
+}
+
+func NewEncrypted[T any](v T) Encrypted[T] {
+	return Encrypted[T]{V: v}
+}
+
+// Stale 判断读取的数据是否使用了非当前密钥加密，可配合 DBAll 重新保存完成密钥轮换。
+func (e Encrypted[T]) Stale() bool {
+	ks := _encryptionKeys.Load()
+	return e.key != "" && (ks == nil || e.key != ks.current)
+}
+
+func (e Encrypted[T]) Value() (driver.Value, error) {
+	ks := _encryptionKeys.Load()
+	if ks == nil {
+		return nil, ErrNoEncryptionKey
+	}
+	data, err := json.Marshal(e.V)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.AesEncryptGCM(data, ks.keys[ks.current])
+	if err != nil {
+		return nil, err
+	}
+	return ks.current + ":" + base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+func (e *Encrypted[T]) Scan(v any) error {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		*e = Encrypted[T]{}
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("cannot scan %T into %T", v, e)
+	}
+
+	id, data, ok := strings.Cut(s, ":")
+	if !ok {
+		return ErrInvalidCipher
+	}
+	ks := _encryptionKeys.Load()
+	if ks == nil || ks.keys[id] == nil {
+		return fmt.Errorf("%w: %s", ErrNoEncryptionKey, id)
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCipher, err)
+	}
+	plain, err := utils.AesDecryptGCM(encrypted, ks.keys[id])
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidCipher, err)
+	}
+	*e = Encrypted[T]{key: id}
+	return json.Unmarshal(plain, &e.V)
+}
+
+func (Encrypted[T]) GormDataType() string {
+	return "string"
+}
+
+// GormDBDataType 密文长度随明文增长，不能使用默认的 varchar(256)。
+func (Encrypted[T]) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "mysql" {
+		return "longtext"
+	}
+	return "text"
+}
+
+func (e Encrypted[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.V)
+}
+
+func (e *Encrypted[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &e.V)
+}
+
+// auditRedact 使审计日志中不记录明文。
+func (Encrypted[T]) auditRedact() {}
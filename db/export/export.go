@@ -0,0 +1,254 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/nzlov/utils/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+type Compression string
+
+const (
+	CompressionNone   Compression = ""
+	CompressionGzip   Compression = "gzip"
+	CompressionZstd   Compression = "zstd"
+	CompressionSnappy Compression = "snappy"
+)
+
+var (
+	ErrFormat      = errors.New("unsupported export format")
+	ErrCompression = errors.New("unsupported export compression")
+)
+
+type Config struct {
+	Compression Compression
+	Columns     []string
+	DBAll       []db.DBAllOption
+}
+
+type Option func(*Config)
+
+// WithCompression 设置压缩方式。
+// CSV/NDJSON 对整个输出流压缩，支持 gzip、zstd；Parquet 使用列块内置压缩，额外支持 snappy。
+func WithCompression(c Compression) Option {
+	return func(cfg *Config) {
+		cfg.Compression = c
+	}
+}
+
+// WithColumns 指定导出的列及顺序，名称为 export 标签或数据库列名。
+func WithColumns(columns ...string) Option {
+	return func(cfg *Config) {
+		cfg.Columns = columns
+	}
+}
+
+// WithDBAllOptions 透传 DBAll 的过滤、排序与分页参数，并发数固定为 1 以保证输出顺序。
+func WithDBAllOptions(ops ...db.DBAllOption) Option {
+	return func(cfg *Config) {
+		cfg.DBAll = append(slices.Clip(cfg.DBAll), ops...)
+	}
+}
+
+// column 是一个导出列，名称优先取 export 标签，其次为数据库列名，标签为 "-" 时不导出。
+type column struct {
+	name  string
+	field *schema.Field
+}
+
+func columns(s *schema.Schema, names []string) ([]column, error) {
+	all := []column{}
+	for _, f := range s.Fields {
+		name := f.Tag.Get("export")
+		if name == "-" || (name == "" && f.DBName == "") {
+			continue
+		}
+		if name == "" {
+			name = f.DBName
+		}
+		all = append(all, column{name: name, field: f})
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	cs := make([]column, 0, len(names))
+	for _, n := range names {
+		found := false
+		for _, c := range all {
+			if c.name == n {
+				cs = append(cs, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", db.ErrInvalidField, n)
+		}
+	}
+	return cs, nil
+}
+
+// normalize 将字段值统一为 nil、bool、int64、uint64、float64、string、[]byte、time.Time 之一，
+// driver.Valuer（如 gorm.DeletedAt）取其数据库值，其余复合类型转为 JSON 字符串。
+func normalize(v any) (any, error) {
+	if vr, ok := v.(driver.Valuer); ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, nil
+		}
+		dv, err := vr.Value()
+		if err != nil {
+			return nil, err
+		}
+		v = dv
+	}
+	if t, ok := v.(time.Time); ok {
+		return t, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if t, ok := rv.Interface().(time.Time); ok {
+		return t, nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+	}
+	data, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+type rowWriter interface {
+	Write(values []any) error
+	Close() error
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func compressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case CompressionNone:
+		return nopCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrCompression, c)
+	}
+}
+
+// Export 使用 DBAll 键集分页流式读取 T 的数据并按 format 写入 w，不会一次性加载全部数据。
+// 返回写入的行数。ctx 中需要通过 db.Ctx 注入数据库。
+func Export[T db.DBModelID](ctx context.Context, w io.Writer, format Format, ops ...Option) (int64, error) {
+	cfg := Config{}
+	for _, op := range ops {
+		op(&cfg)
+	}
+
+	gdb, err := db.TryFor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	stmt := &gorm.Statement{DB: gdb}
+	if err := stmt.Parse(new(T)); err != nil {
+		return 0, err
+	}
+	cs, err := columns(stmt.Schema, cfg.Columns)
+	if err != nil {
+		return 0, err
+	}
+
+	var rw rowWriter
+	switch format {
+	case FormatCSV, FormatNDJSON:
+		cw, err := compressWriter(w, cfg.Compression)
+		if err != nil {
+			return 0, err
+		}
+		if format == FormatCSV {
+			rw, err = newCSVWriter(cw, cs)
+		} else {
+			rw = newNDJSONWriter(cw, cs)
+		}
+		if err != nil {
+			return 0, err
+		}
+	case FormatParquet:
+		if rw, err = newParquetWriter(w, cs, cfg.Compression); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrFormat, format)
+	}
+
+	n := int64(0)
+	values := make([]any, len(cs))
+	err = db.DBAllBatch(ctx, func(ctx context.Context, objs []T) error {
+		for i := range objs {
+			rv := reflect.ValueOf(&objs[i]).Elem()
+			for j, c := range cs {
+				v, _ := c.field.ValueOf(ctx, rv)
+				if values[j], err = normalize(v); err != nil {
+					return fmt.Errorf("export %s: %w", c.name, err)
+				}
+			}
+			if err := rw.Write(values); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	}, append(slices.Clip(cfg.DBAll), db.WithDBAllConcurrency(1))...)
+
+	return n, errors.Join(err, rw.Close())
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nzlov/utils/db"
+	"github.com/parquet-go/parquet-go"
+	"gorm.io/gorm"
+)
+
+type exportTestModel struct {
+	db.Model
+	Name   string `export:"title"`
+	Score  float64
+	Secret string `export:"-"`
+}
+
+func testCtx(t *testing.T, total int) context.Context {
+	t.Helper()
+
+	dbName := fmt.Sprintf("file:export_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	gdb, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&exportTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	for i := 0; i < total; i++ {
+		if err := gdb.Create(&exportTestModel{Name: fmt.Sprintf("n%d", i), Score: float64(i) / 2, Secret: "s"}).Error; err != nil {
+			t.Fatalf("写入测试数据失败: %v", err)
+		}
+	}
+	return db.Ctx(context.Background(), gdb)
+}
+
+// TestExportCSV 验证列映射来自 export 标签且标签为 "-" 的字段不会被导出，压缩后内容可还原。
+func TestExportCSV(t *testing.T) {
+	ctx := testCtx(t, 12)
+
+	buf := bytes.Buffer{}
+	n, err := Export[exportTestModel](ctx, &buf, FormatCSV, WithCompression(CompressionGzip),
+		WithDBAllOptions(db.WithDBAllPageSize(5)))
+	if err != nil || n != 12 {
+		t.Fatalf("导出失败: n=%d err=%v", n, err)
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("解压失败: %v", err)
+	}
+	records, err := csv.NewReader(zr).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	if len(records) != 13 {
+		t.Fatalf("行数不正确: %d", len(records))
+	}
+	header := strings.Join(records[0], ",")
+	if header != "created_at,updated_at,deleted_at,id,title,score" {
+		t.Fatalf("表头不正确: %s", header)
+	}
+	if records[1][3] != "1" || records[1][4] != "n0" || records[12][5] != "5.5" {
+		t.Fatalf("数据不正确: %v %v", records[1], records[12])
+	}
+}
+
+// TestExportNDJSON 验证按 WithColumns 指定的列顺序输出。
+func TestExportNDJSON(t *testing.T) {
+	ctx := testCtx(t, 3)
+
+	buf := bytes.Buffer{}
+	if _, err := Export[exportTestModel](ctx, &buf, FormatNDJSON, WithColumns("id", "title")); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != `{"id":1,"title":"n0"}` {
+		t.Fatalf("输出不正确: %v", lines)
+	}
+	v := map[string]any{}
+	if err := json.Unmarshal([]byte(lines[2]), &v); err != nil || v["title"] != "n2" {
+		t.Fatalf("输出不是合法 JSON: %v %v", v, err)
+	}
+}
+
+// TestExportParquet 验证动态 schema 写出的文件可以被读回，NULL 值保持为空。
+func TestExportParquet(t *testing.T) {
+	ctx := testCtx(t, 4)
+
+	buf := bytes.Buffer{}
+	if _, err := Export[exportTestModel](ctx, &buf, FormatParquet, WithCompression(CompressionSnappy)); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	rows, err := parquet.Read[any](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil && err != io.EOF {
+		t.Fatalf("读取 parquet 失败: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("parquet 行数不正确: %d", len(rows))
+	}
+	last, _ := rows[3].(map[string]any)
+	first, _ := rows[0].(map[string]any)
+	if last["title"] != "n3" || last["score"] != 1.5 || first["deleted_at"] != nil {
+		t.Fatalf("parquet 内容不正确: %v", rows)
+	}
+}
@@ -0,0 +1,189 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/gzip"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+	"gorm.io/gorm/schema"
+)
+
+type csvWriter struct {
+	w   *csv.Writer
+	out io.WriteCloser
+	buf []string
+}
+
+func newCSVWriter(out io.WriteCloser, cs []column) (*csvWriter, error) {
+	w := &csvWriter{w: csv.NewWriter(out), out: out, buf: make([]string, len(cs))}
+	for i, c := range cs {
+		w.buf[i] = c.name
+	}
+	return w, w.w.Write(w.buf)
+}
+
+func (w *csvWriter) Write(values []any) error {
+	for i, v := range values {
+		switch v := v.(type) {
+		case nil:
+			w.buf[i] = ""
+		case bool:
+			w.buf[i] = strconv.FormatBool(v)
+		case int64:
+			w.buf[i] = strconv.FormatInt(v, 10)
+		case uint64:
+			w.buf[i] = strconv.FormatUint(v, 10)
+		case float64:
+			w.buf[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			w.buf[i] = v
+		case []byte:
+			w.buf[i] = base64.StdEncoding.EncodeToString(v)
+		case time.Time:
+			w.buf[i] = v.Format(time.RFC3339Nano)
+		}
+	}
+	return w.w.Write(w.buf)
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return errors.Join(w.w.Error(), w.out.Close())
+}
+
+// ndjsonWriter 按列顺序手动拼接 JSON 对象，保证每行字段顺序与 WithColumns 一致。
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	out  io.WriteCloser
+	keys [][]byte
+}
+
+func newNDJSONWriter(out io.WriteCloser, cs []column) *ndjsonWriter {
+	w := &ndjsonWriter{w: bufio.NewWriter(out), out: out, keys: make([][]byte, len(cs))}
+	for i, c := range cs {
+		w.keys[i], _ = json.Marshal(c.name)
+	}
+	return w
+}
+
+func (w *ndjsonWriter) Write(values []any) error {
+	w.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		w.w.Write(w.keys[i])
+		w.w.WriteByte(':')
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.w.Write(data)
+	}
+	w.w.WriteByte('}')
+	return w.w.WriteByte('\n')
+}
+
+func (w *ndjsonWriter) Close() error {
+	return errors.Join(w.w.Flush(), w.out.Close())
+}
+
+// parquetWriter 根据字段类型动态生成 schema，所有列均为 optional 以表示 NULL。
+type parquetWriter struct {
+	w     *parquet.Writer
+	index []int
+	row   parquet.Row
+}
+
+func parquetNode(c column) parquet.Node {
+	t := c.field.IndirectFieldType
+	if t == reflect.TypeOf(time.Time{}) || c.field.DataType == schema.Time {
+		return parquet.Optional(parquet.Timestamp(parquet.Microsecond))
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return parquet.Optional(parquet.Leaf(parquet.BooleanType))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return parquet.Optional(parquet.Int(64))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return parquet.Optional(parquet.Uint(64))
+	case reflect.Float32, reflect.Float64:
+		return parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return parquet.Optional(parquet.Leaf(parquet.ByteArrayType))
+		}
+	}
+	return parquet.Optional(parquet.String())
+}
+
+func newParquetWriter(out io.Writer, cs []column, c Compression) (*parquetWriter, error) {
+	group := parquet.Group{}
+	for _, v := range cs {
+		group[v.name] = parquetNode(v)
+	}
+	ps := parquet.NewSchema("export", group)
+
+	ops := []parquet.WriterOption{ps}
+	switch c {
+	case CompressionNone:
+	case CompressionGzip:
+		ops = append(ops, parquet.Compression(&gzip.Codec{}))
+	case CompressionZstd:
+		ops = append(ops, parquet.Compression(&zstd.Codec{}))
+	case CompressionSnappy:
+		ops = append(ops, parquet.Compression(&snappy.Codec{}))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrCompression, c)
+	}
+
+	// Group 中的列按名称排序，记录每个导出列在 schema 中的列序号
+	pos := map[string]int{}
+	for i, path := range ps.Columns() {
+		pos[path[0]] = i
+	}
+	w := &parquetWriter{
+		w:     parquet.NewWriter(out, ops...),
+		index: make([]int, len(cs)),
+		row:   make(parquet.Row, len(cs)),
+	}
+	for i, v := range cs {
+		w.index[i] = pos[v.name]
+	}
+	return w, nil
+}
+
+func (w *parquetWriter) Write(values []any) error {
+	for i, v := range values {
+		idx := w.index[i]
+		switch v := v.(type) {
+		case nil:
+			w.row[idx] = parquet.NullValue().Level(0, 0, idx)
+			continue
+		case time.Time:
+			w.row[idx] = parquet.Int64Value(v.UnixMicro()).Level(0, 1, idx)
+			continue
+		case uint64:
+			w.row[idx] = parquet.Int64Value(int64(v)).Level(0, 1, idx)
+			continue
+		}
+		w.row[idx] = parquet.ValueOf(v).Level(0, 1, idx)
+	}
+	_, err := w.w.WriteRows([]parquet.Row{w.row})
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	return w.w.Close()
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Cond 结构化条件，可以直接从 JSON 解析。Field 非空时为字段条件，And/Or 为子条件组合，
+// 同一个节点上同时设置的部分之间为 AND。
+type Cond struct {
+	Filter
+	And []Cond `json:"and,omitempty"`
+	Or  []Cond `json:"or,omitempty"`
+}
+
+func NewCond(field, op string, value any) Cond {
+	return Cond{Filter: Filter{Field: field, Op: op, Value: value}}
+}
+
+func AndCond(conds ...Cond) Cond {
+	return Cond{And: conds}
+}
+
+func OrCond(conds ...Cond) Cond {
+	return Cond{Or: conds}
+}
+
+// buildCond 递归生成条件，字段经 buildFilter 与 schema 校验，条件为空时返回空字符串。
+func buildCond(s *schema.Schema, c Cond) (string, []any, error) {
+	parts := []string{}
+	args := []any{}
+	if c.Field != "" {
+		query, vs, err := buildFilter(s, c.Filter)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, query)
+		args = append(args, vs...)
+	}
+	for _, group := range []struct {
+		conds []Cond
+		sep   string
+	}{{c.And, " AND "}, {c.Or, " OR "}} {
+		subs := []string{}
+		for _, sc := range group.conds {
+			query, vs, err := buildCond(s, sc)
+			if err != nil {
+				return "", nil, err
+			}
+			if query == "" {
+				continue
+			}
+			subs = append(subs, "("+query+")")
+			args = append(args, vs...)
+		}
+		if len(subs) > 0 {
+			parts = append(parts, strings.Join(subs, group.sep))
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0], args, nil
+	}
+	for i, v := range parts {
+		parts[i] = "(" + v + ")"
+	}
+	return strings.Join(parts, " AND "), args, nil
+}
+
+type filterKey string
+
+var _filterKey filterKey = "nzlov@filterKey"
+
+type ctxFilter struct {
+	name     string
+	model    reflect.Type
+	cond     Cond
+	disabled bool
+}
+
+type ctxFilters []ctxFilter
+
+func getCtxFilters(ctx context.Context) ctxFilters {
+	fs, _ := ctx.Value(_filterKey).(ctxFilters)
+	return fs
+}
+
+// FilterCtx 设置名为 name 的结构化条件，只作用于模型 T 的查询（Loader、Repository、Cursor、DBAll 等），
+// 同名同模型的条件会被替换。字段在查询时与 T 的 schema 校验，不存在时查询返回 ErrInvalidField。
+func FilterCtx[T any](ctx context.Context, name string, cond Cond) context.Context {
+	model := reflect.TypeOf((*T)(nil)).Elem()
+	fs := slices.Clone(getCtxFilters(ctx))
+	f := ctxFilter{name: name, model: model, cond: cond}
+	if i := slices.IndexFunc(fs, func(v ctxFilter) bool {
+		return v.name == name && v.model == model
+	}); i >= 0 {
+		fs[i] = f
+	} else {
+		fs = append(fs, f)
+	}
+	return context.WithValue(ctx, _filterKey, fs)
+}
+
+// DisableFilterCtx 关闭指定名称的条件（对所有模型），可以用 EnableFilterCtx 重新打开。
+func DisableFilterCtx(ctx context.Context, names ...string) context.Context {
+	return toggleFilterCtx(ctx, true, names)
+}
+
+func EnableFilterCtx(ctx context.Context, names ...string) context.Context {
+	return toggleFilterCtx(ctx, false, names)
+}
+
+func toggleFilterCtx(ctx context.Context, disabled bool, names []string) context.Context {
+	fs := slices.Clone(getCtxFilters(ctx))
+	for i := range fs {
+		if slices.Contains(names, fs[i].name) {
+			fs[i].disabled = disabled
+		}
+	}
+	return context.WithValue(ctx, _filterKey, fs)
+}
+
+// hasCtxFilters 上下文中是否有 WhereCtx 条件或启用的结构化条件。
+func hasCtxFilters(ctx context.Context) bool {
+	if len(GetCtxWheres(ctx)) > 0 {
+		return true
+	}
+	return slices.ContainsFunc(getCtxFilters(ctx), func(f ctxFilter) bool {
+		return !f.disabled
+	})
+}
+
+// ctxFilterExpr 合并上下文中作用于 s 对应模型且启用的结构化条件，没有时返回 nil。
+func ctxFilterExpr(ctx context.Context, s *schema.Schema) (clause.Expression, error) {
+	parts := []string{}
+	args := []any{}
+	for _, f := range getCtxFilters(ctx) {
+		if f.disabled || f.model != s.ModelType {
+			continue
+		}
+		query, vs, err := buildCond(s, f.cond)
+		if err != nil {
+			return nil, err
+		}
+		if query == "" {
+			continue
+		}
+		parts = append(parts, "("+query+")")
+		args = append(args, vs...)
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	return clause.Expr{SQL: strings.Join(parts, " AND "), Vars: args}, nil
+}
+
+// applyCtxWheres 附加 WhereCtx 设置的原始条件以及 FilterCtx 中作用于 s 的结构化条件。
+func applyCtxWheres(ctx context.Context, db *gorm.DB, s *schema.Schema) (*gorm.DB, error) {
+	for _, w := range GetCtxWheres(ctx) {
+		db = db.Where(w.Query, w.Args...)
+	}
+	expr, err := ctxFilterExpr(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if expr != nil {
+		db = db.Where(expr)
+	}
+	return db, nil
+}
+
+// ctxFilterFor 与 ctxFilterExpr 相同，按 T 解析 schema。
+func ctxFilterFor[T any](ctx context.Context, db *gorm.DB) (clause.Expression, error) {
+	s, err := parseSchema(db, new(T))
+	if err != nil {
+		return nil, err
+	}
+	return ctxFilterExpr(ctx, s)
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/vikstrous/dataloadgen"
+)
+
+type filterCtxTestModel struct {
+	Model
+	Org  string
+	Code string
+}
+
+// TestFilterCtx 确认结构化条件按模型生效、支持 AND/OR 组合、可以按名称开关，并与 schema 校验。
+func TestFilterCtx(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&filterCtxTestModel{}, &loaderManyTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	objs := []filterCtxTestModel{{Org: "a", Code: "1"}, {Org: "b", Code: "2"}, {Org: "b", Code: "3"}}
+	if err := db.Create(&objs).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := db.Create(&loaderManyTestModel{UserID: "u1", Amount: 1}).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	var cond Cond
+	if err := json.Unmarshal([]byte(`{"or":[{"field":"Code","op":"=","value":"1"},{"and":[{"field":"org","op":"=","value":"b"},{"field":"code","op":"in","value":["2","9"]}]}]}`), &cond); err != nil {
+		t.Fatalf("解析条件失败: %v", err)
+	}
+	ctx := FilterCtx[filterCtxTestModel](testCtx(db), "visible", cond)
+	load := func(ctx context.Context) []error {
+		_, err := KeyLoader("id", func(v filterCtxTestModel) uint { return v.ID }).
+			LoadAll(ctx, []uint{objs[0].ID, objs[1].ID, objs[2].ID})
+		if err == nil {
+			return make([]error, 3)
+		}
+		return err.(dataloadgen.ErrorSlice)
+	}
+
+	if es := load(ctx); es[0] != nil || es[1] != nil || !errors.Is(es[2], ErrNotFound) {
+		t.Fatalf("组合条件过滤不正确: %v", es)
+	}
+	if rs, err := LoaderMany("user_id", func(v loaderManyTestModel) string { return v.UserID }).Load(ctx, "u1"); err != nil || len(rs) != 1 {
+		t.Fatalf("条件不应作用于其它模型: %v %v", rs, err)
+	}
+
+	ctx = DisableFilterCtx(ctx, "visible")
+	if es := load(ctx); es[2] != nil {
+		t.Fatalf("关闭后不应过滤: %v", es)
+	}
+	if es := load(EnableFilterCtx(ctx, "visible")); !errors.Is(es[2], ErrNotFound) {
+		t.Fatalf("重新打开后应过滤: %v", es)
+	}
+
+	bad := FilterCtx[filterCtxTestModel](ctx, "bad", NewCond("secret; drop", "=", 1))
+	if es := load(bad); !errors.Is(es[0], ErrInvalidField) {
+		t.Fatalf("不存在的字段应返回 ErrInvalidField: %v", es)
+	}
+}
@@ -2,6 +2,10 @@ package db
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -16,25 +20,211 @@ type IDGen struct {
 // Gen generates a new ID for the given name
 // Uses Upsert to atomically increment the sequence number
 func GenID(ctx context.Context, name string) (int64, error) {
-	db := For(ctx)
-	idGen := IDGen{
-		Name: name,
-		Num:  1,
-	}
+	_, end, err := GenIDBlock(ctx, name, 1)
+	return end, err
+}
 
-	n := int64(0)
-	return n, Tx(ctx, func(context.Context) error {
+// GenIDBlock 一次预留 size 个连续 ID，返回闭区间 [start, end]。
+// 不使用 RETURNING，递增后在同一事务内回查，兼容 MySQL。
+// ctx 处于事务中时随该事务提交或回滚。
+func GenIDBlock(ctx context.Context, name string, size int64) (start, end int64, err error) {
+	if size <= 0 {
+		size = 1
+	}
+	err = Tx(ctx, func(ctx context.Context) error {
+		db := For(ctx)
 		if err := db.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "name"}},
-			DoUpdates: clause.Assignments(map[string]any{"num": gorm.Expr("id_gens.num + 1")}),
-		},
-			clause.Returning{
-				Columns: []clause.Column{{Name: "num"}},
-			},
-		).Create(&idGen).Error; err != nil {
+			DoUpdates: clause.Assignments(map[string]any{"num": gorm.Expr("id_gens.num + ?", size)}),
+		}).Create(&IDGen{Name: name, Num: size}).Error; err != nil {
 			return err
 		}
-		n = idGen.Num
-		return nil
+		// 上面的写入已锁住该行，这里读到的是本事务递增后的值
+		return db.Model(&IDGen{}).Select("num").Where("name = ?", name).Take(&end).Error
 	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return end - size + 1, end, nil
+}
+
+type IDSegmentConfig struct {
+	Step     int64
+	Prefetch float64
+}
+
+type IDSegmentOption func(*IDSegmentConfig)
+
+// WithIDSegmentStep 设置每次从数据库预留的号段大小，默认 1000。
+func WithIDSegmentStep(step int64) IDSegmentOption {
+	return func(cfg *IDSegmentConfig) {
+		if step > 0 {
+			cfg.Step = step
+		}
+	}
+}
+
+// WithIDSegmentPrefetch 当前号段剩余比例低于 ratio 时在后台预取下一个号段，默认 0.2。
+// ratio 为 0 时不预取，用完后同步获取。
+func WithIDSegmentPrefetch(ratio float64) IDSegmentOption {
+	return func(cfg *IDSegmentConfig) {
+		if ratio >= 0 && ratio <= 1 {
+			cfg.Prefetch = ratio
+		}
+	}
+}
+
+type idRange struct {
+	next, end int64
+}
+
+// IDSegment 号段发号器，按块从 id_gens 表预留 ID 后在内存中分配，
+// 并使用双缓冲在当前号段快用完时预取下一个号段，避免每个 ID 都访问数据库。
+// 号段在业务事务之外预留，事务回滚不会回收已发出的 ID；进程重启时未用完的号段会被跳过，ID 单调递增但不保证连续。
+type IDSegment struct {
+	name string
+	cfg  IDSegmentConfig
+
+	mu      sync.Mutex
+	cur     idRange
+	next    *idRange
+	loading chan struct{} // 正在获取号段时非空，获取完成后关闭
+	err     error         // 最近一次预取的错误
+}
+
+func NewIDSegment(name string, ops ...IDSegmentOption) *IDSegment {
+	cfg := IDSegmentConfig{
+		Step:     1000,
+		Prefetch: 0.2,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	return &IDSegment{name: name, cfg: cfg, cur: idRange{next: 1, end: 0}}
+}
+
+func (g *IDSegment) load(ctx context.Context, done chan struct{}) {
+	start, end, err := GenIDBlock(WithoutTx(ctx), g.name, g.cfg.Step)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		g.next = &idRange{next: start, end: end}
+	}
+	g.err = err
+	g.loading = nil
+	close(done)
+}
+
+// startLoad 在后台获取新号段，调用方需持有锁。
+func (g *IDSegment) startLoad(ctx context.Context) chan struct{} {
+	done := make(chan struct{})
+	g.loading = done
+	go g.load(context.WithoutCancel(ctx), done)
+	return done
+}
+
+// Next 返回下一个 ID，ctx 中需要有 db（可以处于事务中，号段会在事务之外预留）。
+func (g *IDSegment) Next(ctx context.Context) (int64, error) {
+	g.mu.Lock()
+	for {
+		if g.cur.next <= g.cur.end {
+			n := g.cur.next
+			g.cur.next++
+			if g.next == nil && g.loading == nil && g.cfg.Prefetch > 0 &&
+				float64(g.cur.end-g.cur.next+1) < float64(g.cfg.Step)*g.cfg.Prefetch {
+				g.startLoad(ctx)
+			}
+			g.mu.Unlock()
+			return n, nil
+		}
+		if g.next != nil {
+			g.cur, g.next = *g.next, nil
+			continue
+		}
+
+		done := g.loading
+		if done == nil {
+			done = g.startLoad(ctx)
+		}
+		g.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		g.mu.Lock()
+		if g.next == nil && g.loading == nil && g.err != nil {
+			err := g.err
+			g.err = nil
+			g.mu.Unlock()
+			return 0, err
+		}
+	}
+}
+
+// NextString 返回按 ops 格式化后的下一个 ID。
+func (g *IDSegment) NextString(ctx context.Context, ops ...IDFormatOption) (string, error) {
+	n, err := g.Next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return FormatID(n, ops...), nil
+}
+
+type IDFormatConfig struct {
+	Prefix string
+	Layout string
+	Width  int
+	Now    func() time.Time
+}
+
+type IDFormatOption func(*IDFormatConfig)
+
+// WithIDPrefix 设置编号前缀，如 "SO"。
+func WithIDPrefix(prefix string) IDFormatOption {
+	return func(cfg *IDFormatConfig) {
+		cfg.Prefix = prefix
+	}
+}
+
+// WithIDDate 在前缀后加入按 layout 格式化的当前时间，如 "20060102"。
+func WithIDDate(layout string) IDFormatOption {
+	return func(cfg *IDFormatConfig) {
+		cfg.Layout = layout
+	}
+}
+
+// WithIDPadding 序号左侧补零到 width 位，超出时保留完整序号。
+func WithIDPadding(width int) IDFormatOption {
+	return func(cfg *IDFormatConfig) {
+		cfg.Width = width
+	}
+}
+
+// WithIDNow 设置获取当前时间的函数，默认 time.Now。
+func WithIDNow(now func() time.Time) IDFormatOption {
+	return func(cfg *IDFormatConfig) {
+		cfg.Now = now
+	}
+}
+
+// FormatID 将序号格式化为 前缀+日期+补零序号 形式的编号，如 SO20240101000042。
+func FormatID(n int64, ops ...IDFormatOption) string {
+	cfg := IDFormatConfig{Now: time.Now}
+	for _, op := range ops {
+		op(&cfg)
+	}
+
+	b := strings.Builder{}
+	b.WriteString(cfg.Prefix)
+	if cfg.Layout != "" {
+		b.WriteString(cfg.Now().Format(cfg.Layout))
+	}
+	s := strconv.FormatInt(n, 10)
+	for i := len(s); i < cfg.Width; i++ {
+		b.WriteByte('0')
+	}
+	b.WriteString(s)
+	return b.String()
 }
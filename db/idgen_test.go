@@ -0,0 +1,84 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestGenIDBlock 确认号段连续分配且与 GenID 共用同一计数。
+func TestGenIDBlock(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&IDGen{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	ctx := testCtx(db)
+
+	if n, err := GenID(ctx, "order"); err != nil || n != 1 {
+		t.Fatalf("首个 ID 不正确: %d %v", n, err)
+	}
+	start, end, err := GenIDBlock(ctx, "order", 10)
+	if err != nil || start != 2 || end != 11 {
+		t.Fatalf("号段不正确: [%d, %d] %v", start, end, err)
+	}
+	if n, err := GenID(ctx, "order"); err != nil || n != 12 {
+		t.Fatalf("号段后的 ID 不正确: %d %v", n, err)
+	}
+}
+
+// TestIDSegment 确认并发取号不重复，并在号段用完后自动获取下一段。
+func TestIDSegment(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&IDGen{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	ctx := testCtx(db)
+	g := NewIDSegment("order", WithIDSegmentStep(10), WithIDSegmentPrefetch(0.5))
+
+	var (
+		mu   sync.Mutex
+		seen = map[int64]bool{}
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				n, err := g.Next(ctx)
+				if err != nil {
+					t.Errorf("取号失败: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[n] {
+					t.Errorf("ID 重复: %d", n)
+				}
+				seen[n] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 100 {
+		t.Fatalf("取号数量不正确: %d", len(seen))
+	}
+
+	s, err := g.NextString(ctx, WithIDPrefix("SO"), WithIDDate("20060102"), WithIDPadding(6),
+		WithIDNow(func() time.Time { return time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC) }))
+	if err != nil {
+		t.Fatalf("取号失败: %v", err)
+	}
+	if len(s) != len("SO20240102000000") || s[:10] != "SO20240102" {
+		t.Fatalf("编号格式不正确: %s", s)
+	}
+}
+
+func TestFormatID(t *testing.T) {
+	if s := FormatID(42, WithIDPrefix("NO"), WithIDPadding(5)); s != "NO00042" {
+		t.Fatalf("编号格式不正确: %s", s)
+	}
+	if s := FormatID(123456, WithIDPadding(3)); s != "123456" {
+		t.Fatalf("超出宽度时应保留完整序号: %s", s)
+	}
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// jsonDBDataType 返回各数据库中保存 JSON 的列类型：Postgres 为 jsonb，MySQL 为 JSON，其余为 TEXT。
+func jsonDBDataType(db *gorm.DB) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "jsonb"
+	case "mysql":
+		return "JSON"
+	default:
+		return "TEXT"
+	}
+}
+
+func jsonValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func jsonScan(dst any, v any) error {
+	var data []byte
+	switch v := v.(type) {
+	case nil:
+		data = []byte("null")
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", v, dst)
+	}
+	return json.Unmarshal(data, dst)
+}
+
+// jsonPath 将键路径转换为 MySQL/SQLite 的 JSON path，如 $."a"."b"。
+func jsonPath(path []string) string {
+	b := strings.Builder{}
+	b.WriteByte('$')
+	for _, p := range path {
+		b.WriteString(`."`)
+		b.WriteString(strings.ReplaceAll(p, `"`, `\"`))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func dialectName(builder clause.Builder) string {
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		return stmt.Dialector.Name()
+	}
+	return ""
+}
+
+type jsonContains struct {
+	column string
+	value  any
+}
+
+// JSONContains 查询 JSON 列是否包含 value：列为数组时判断是否含有该元素，
+// Postgres/MySQL 上列为对象且 value 为对象时判断是否包含其全部键值。
+// SQLite 只支持数组包含标量元素。
+func JSONContains(column string, value any) clause.Expression {
+	return jsonContains{column: column, value: value}
+}
+
+func (e jsonContains) Build(builder clause.Builder) {
+	data, err := json.Marshal(e.value)
+	if err != nil {
+		builder.AddError(err)
+		return
+	}
+	switch dialectName(builder) {
+	case "postgres":
+		builder.WriteQuoted(e.column)
+		builder.WriteString(" @> ")
+		builder.AddVar(builder, string(data))
+		builder.WriteString("::jsonb")
+	case "mysql":
+		builder.WriteString("JSON_CONTAINS(")
+		builder.WriteQuoted(e.column)
+		builder.WriteByte(',')
+		builder.AddVar(builder, string(data))
+		builder.WriteByte(')')
+	default:
+		builder.WriteString("EXISTS (SELECT 1 FROM json_each(")
+		builder.WriteQuoted(e.column)
+		builder.WriteString(") WHERE json_each.value = ")
+		builder.AddVar(builder, e.value)
+		builder.WriteByte(')')
+	}
+}
+
+type jsonHasKey struct {
+	column string
+	path   []string
+}
+
+// JSONHasKey 查询 JSON 对象中是否存在 path 指定的键，值为 JSON null 时也视为存在。
+func JSONHasKey(column string, path ...string) clause.Expression {
+	return jsonHasKey{column: column, path: path}
+}
+
+func (e jsonHasKey) Build(builder clause.Builder) {
+	switch dialectName(builder) {
+	case "postgres":
+		writePGPath(builder, e.column, e.path)
+		builder.WriteString(" IS NOT NULL")
+	case "mysql":
+		builder.WriteString("JSON_CONTAINS_PATH(")
+		builder.WriteQuoted(e.column)
+		builder.WriteString(",'one',")
+		builder.AddVar(builder, jsonPath(e.path))
+		builder.WriteByte(')')
+	default:
+		builder.WriteString("json_type(")
+		builder.WriteQuoted(e.column)
+		builder.WriteByte(',')
+		builder.AddVar(builder, jsonPath(e.path))
+		builder.WriteString(") IS NOT NULL")
+	}
+}
+
+type jsonPathEq struct {
+	column string
+	path   []string
+	value  any
+}
+
+// JSONPathEq 查询 JSON 对象中 path 指定的值是否等于 value，按 JSON 值比较（1 与 "1" 不相等）。
+func JSONPathEq(column string, value any, path ...string) clause.Expression {
+	return jsonPathEq{column: column, path: path, value: value}
+}
+
+func (e jsonPathEq) Build(builder clause.Builder) {
+	data, err := json.Marshal(e.value)
+	if err != nil {
+		builder.AddError(err)
+		return
+	}
+	switch dialectName(builder) {
+	case "postgres":
+		writePGPath(builder, e.column, e.path)
+		builder.WriteString(" = ")
+		builder.AddVar(builder, string(data))
+		builder.WriteString("::jsonb")
+	case "mysql":
+		builder.WriteString("JSON_EXTRACT(")
+		builder.WriteQuoted(e.column)
+		builder.WriteByte(',')
+		builder.AddVar(builder, jsonPath(e.path))
+		builder.WriteString(") = CAST(")
+		builder.AddVar(builder, string(data))
+		builder.WriteString(" AS JSON)")
+	default:
+		// json_extract 对标量返回 SQL 值，对象和数组返回 JSON 文本，需按 JSON 解码后的类型分别比较
+		var v any
+		json.Unmarshal(data, &v)
+		switch v.(type) {
+		case nil:
+			builder.WriteString("json_type(")
+			builder.WriteQuoted(e.column)
+			builder.WriteByte(',')
+			builder.AddVar(builder, jsonPath(e.path))
+			builder.WriteString(") = 'null'")
+		case map[string]any, []any:
+			builder.WriteString("json_extract(")
+			builder.WriteQuoted(e.column)
+			builder.WriteByte(',')
+			builder.AddVar(builder, jsonPath(e.path))
+			builder.WriteString(") = json(")
+			builder.AddVar(builder, string(data))
+			builder.WriteByte(')')
+		default:
+			builder.WriteString("json_extract(")
+			builder.WriteQuoted(e.column)
+			builder.WriteByte(',')
+			builder.AddVar(builder, jsonPath(e.path))
+			builder.WriteString(") = ")
+			builder.AddVar(builder, v)
+		}
+	}
+}
+
+// writePGPath 写入 jsonb_extract_path(column, k1, k2...)，键作为参数传入避免注入。
+func writePGPath(builder clause.Builder, column string, path []string) {
+	builder.WriteString("jsonb_extract_path(")
+	builder.WriteQuoted(column)
+	for _, p := range path {
+		builder.WriteByte(',')
+		builder.AddVar(builder, p)
+	}
+	builder.WriteByte(')')
+}
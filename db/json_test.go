@@ -0,0 +1,97 @@
+package db
+
+import (
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type jsonTestModel struct {
+	Model
+	Tags  Array[string]
+	Attrs Map[string, any]
+}
+
+// TestJSONQuery 确认 Array/Map 可直接读写，并验证 SQLite 上的 JSON 查询条件。
+func TestJSONQuery(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&jsonTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	objs := []jsonTestModel{
+		{Tags: Array[string]{"a", "b"}, Attrs: Map[string, any]{"color": "red", "size": 1, "meta": map[string]any{"x": true}}},
+		{Tags: Array[string]{"b", "c"}, Attrs: Map[string, any]{"color": "blue", "size": 2, "extra": nil}},
+		{},
+	}
+	if err := db.Create(&objs).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	got := jsonTestModel{}
+	if err := db.First(&got, objs[0].ID).Error; err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "b" || got.Attrs["color"] != "red" {
+		t.Fatalf("读取结果不正确: %+v", got)
+	}
+
+	cases := []struct {
+		name string
+		expr any
+		want int
+	}{
+		{"contains", JSONContains("tags", "b"), 2},
+		{"contains-one", JSONContains("tags", "a"), 1},
+		{"has-key", JSONHasKey("attrs", "extra"), 1},
+		{"has-nested-key", JSONHasKey("attrs", "meta", "x"), 1},
+		{"path-eq-string", JSONPathEq("attrs", "blue", "color"), 1},
+		{"path-eq-number", JSONPathEq("attrs", 1, "size"), 1},
+		{"path-eq-bool", JSONPathEq("attrs", true, "meta", "x"), 1},
+		{"path-eq-null", JSONPathEq("attrs", nil, "extra"), 1},
+		{"path-eq-object", JSONPathEq("attrs", map[string]any{"x": true}, "meta"), 1},
+	}
+	for _, c := range cases {
+		n := int64(0)
+		if err := db.Model(&jsonTestModel{}).Where(c.expr).Count(&n).Error; err != nil {
+			t.Fatalf("%s 查询失败: %v", c.name, err)
+		}
+		if int(n) != c.want {
+			t.Fatalf("%s 查询数量不正确: %d != %d", c.name, n, c.want)
+		}
+	}
+}
+
+// TestJSONQueryDialect 确认 Postgres/MySQL 生成各自的 JSON 运算符。
+func TestJSONQueryDialect(t *testing.T) {
+	pg, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("打开 postgres 失败: %v", err)
+	}
+	my, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(localhost)/test", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("打开 mysql 失败: %v", err)
+	}
+
+	cases := []struct {
+		db   *gorm.DB
+		expr any
+		want string
+	}{
+		{pg, JSONContains("tags", "a"), `SELECT * FROM "json_test_models" WHERE "tags" @> '"a"'::jsonb AND "json_test_models"."deleted_at" IS NULL`},
+		{pg, JSONHasKey("attrs", "a", "b"), `SELECT * FROM "json_test_models" WHERE jsonb_extract_path("attrs",'a','b') IS NOT NULL AND "json_test_models"."deleted_at" IS NULL`},
+		{pg, JSONPathEq("attrs", 1, "a"), `SELECT * FROM "json_test_models" WHERE jsonb_extract_path("attrs",'a') = '1'::jsonb AND "json_test_models"."deleted_at" IS NULL`},
+		{my, JSONContains("tags", "a"), "SELECT * FROM `json_test_models` WHERE JSON_CONTAINS(`tags`,'\"a\"') AND `json_test_models`.`deleted_at` IS NULL"},
+		{my, JSONHasKey("attrs", "a"), "SELECT * FROM `json_test_models` WHERE JSON_CONTAINS_PATH(`attrs`,'one','$.\"a\"') AND `json_test_models`.`deleted_at` IS NULL"},
+		{my, JSONPathEq("attrs", 1, "a"), "SELECT * FROM `json_test_models` WHERE JSON_EXTRACT(`attrs`,'$.\"a\"') = CAST('1' AS JSON) AND `json_test_models`.`deleted_at` IS NULL"},
+	}
+	for _, c := range cases {
+		sql := c.db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			return tx.Where(c.expr).Find(&[]jsonTestModel{})
+		})
+		if sql != c.want {
+			t.Fatalf("生成的 SQL 不正确:\n%s\n%s", sql, c.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// keysetKey 是键集分页中的一个排序键。
+type keysetKey struct {
+	field *schema.Field
+	desc  bool
+}
+
+func (k keysetKey) column(s *schema.Schema) string {
+	return s.Table + "." + k.field.DBName
+}
+
+// keysetKeys 把 Sort 解析为排序键，主键不在其中时自动追加为最后一个键以保证顺序唯一。
+// 返回的签名用于校验游标或断点是否与当前排序一致。
+func keysetKeys(s *schema.Schema, sorts []Sort) ([]keysetKey, string, error) {
+	keys := make([]keysetKey, 0, len(sorts)+1)
+	sign := make([]string, 0, len(sorts)+1)
+	hasPK := false
+	for _, v := range sorts {
+		f := s.LookUpField(v.Field)
+		if f == nil || f.DBName == "" {
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidField, v.Field)
+		}
+		if f == s.PrioritizedPrimaryField {
+			hasPK = true
+		}
+		keys = append(keys, keysetKey{field: f, desc: v.Desc})
+	}
+	if !hasPK {
+		if s.PrioritizedPrimaryField == nil {
+			return nil, "", fmt.Errorf("%w: primary key", ErrInvalidField)
+		}
+		keys = append(keys, keysetKey{field: s.PrioritizedPrimaryField})
+	}
+	for _, k := range keys {
+		if k.desc {
+			sign = append(sign, k.field.DBName+" desc")
+		} else {
+			sign = append(sign, k.field.DBName)
+		}
+	}
+	return keys, strings.Join(sign, ","), nil
+}
+
+// keysetWhere 生成“位于 values 之后”的条件，reverse 为 true 时生成“位于 values 之前”。
+// 条件展开为 OR-of-AND 形式而不是行值比较，以兼容升降序混合以及 sqlite/mysql。
+func keysetWhere(db *gorm.DB, s *schema.Schema, keys []keysetKey, values []any, reverse bool) *gorm.DB {
+	ors := make([]string, 0, len(keys))
+	args := []any{}
+	for i, k := range keys {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].column(s)+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if k.desc != reverse {
+			op = " < ?"
+		}
+		parts = append(parts, k.column(s)+op)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(parts, " AND ")+")")
+	}
+	return db.Where(strings.Join(ors, " OR "), args...)
+}
+
+func keysetOrder(db *gorm.DB, s *schema.Schema, keys []keysetKey, reverse bool) *gorm.DB {
+	for _, k := range keys {
+		if k.desc != reverse {
+			db = db.Order(k.column(s) + " DESC")
+		} else {
+			db = db.Order(k.column(s))
+		}
+	}
+	return db
+}
+
+// keysetEncode 取出 obj 中排序键的值并序列化，obj 必须是指针。
+func keysetEncode(ctx context.Context, keys []keysetKey, obj any) ([]json.RawMessage, error) {
+	values := make([]json.RawMessage, len(keys))
+	for i, v := range keysetValues(ctx, keys, obj) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = data
+	}
+	return values, nil
+}
+
+// keysetDecode 按字段类型还原排序键的值，避免时间等类型以字符串参与比较。
+func keysetDecode(keys []keysetKey, raw []json.RawMessage) ([]any, error) {
+	if len(raw) != len(keys) {
+		return nil, fmt.Errorf("%w: key count mismatch", ErrInvalidCursor)
+	}
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		v := reflect.New(k.field.FieldType)
+		if err := json.Unmarshal(raw[i], v.Interface()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
+
+// keysetValues 取出 obj 中排序键的值，obj 必须是指针。
+func keysetValues(ctx context.Context, keys []keysetKey, obj any) []any {
+	rv := reflect.ValueOf(obj).Elem()
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		values[i], _ = k.field.ValueOf(ctx, rv)
+	}
+	return values
+}
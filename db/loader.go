@@ -20,6 +20,9 @@ type where struct {
 
 type wheres []where
 
+// WhereCtx 追加原始 SQL 条件，会作用于所有模型的上下文查询。
+//
+// Deprecated: 使用按模型生效、与 schema 校验的 FilterCtx。
 func WhereCtx(ctx context.Context, query string, args ...any) context.Context {
 	ws := GetCtxWheres(ctx)
 	ws = append(ws, where{Query: query, Args: args})
@@ -52,14 +55,35 @@ func Loader[T any](column string, key func(T) string, options ...dataloadgen.Opt
 }
 
 func LoaderCtx[T any](column string, key func(context.Context, T) string, options ...dataloadgen.Option) *dataloadgen.Loader[string, T] {
-	return dataloadgen.NewLoader(func(ctx context.Context, keys []string) ([]T, []error) {
-		db := gorm.G[T](For(ctx)).Scopes()
+	return dataloadgen.NewLoader(loaderFetch(column, key), options...)
+}
+
+func loaderFetch[T any](column string, key func(context.Context, T) string) func(context.Context, []string) ([]T, []error) {
+	return func(ctx context.Context, keys []string) ([]T, []error) {
 		rs := make([]T, len(keys))
 		es := make([]error, len(keys))
+		gdb, err := TryFor(ctx)
+		if err != nil {
+			for i := range keys {
+				es[i] = err
+			}
+			return rs, es
+		}
+		db := gorm.G[T](gdb).Scopes()
 		wheres := GetCtxWheres(ctx)
 		for _, w := range wheres {
 			db = db.Where(w.Query, w.Args...)
 		}
+		expr, err := ctxFilterFor[T](ctx, gdb)
+		if err != nil {
+			for i := range keys {
+				es[i] = err
+			}
+			return rs, es
+		}
+		if expr != nil {
+			db = db.Where(expr)
+		}
 		objs, err := db.Where(column+" in (?)", keys).Find(ctx)
 		if err != nil {
 			for i := range keys {
@@ -79,7 +103,7 @@ func LoaderCtx[T any](column string, key func(context.Context, T) string, option
 			}
 		}
 		return rs, es
-	}, options...)
+	}
 }
 
 func LoadersKey(keys ...string) string {
@@ -91,6 +115,8 @@ var (
 	ErrKey      = fmt.Errorf("invalid key format")
 )
 
+// Loaders 按各列分别 IN 查询后再用 LoadersKey 拼接的字符串对应，值中含有分隔符时会出错，
+// 新代码请使用 CompositeLoader。
 func Loaders[T any](columns []string, key func(T) []string, options ...dataloadgen.Option) *dataloadgen.Loader[string, T] {
 	return LoaderCtxs(columns, func(ctx context.Context, t T) []string {
 		return key(t)
@@ -98,11 +124,21 @@ func Loaders[T any](columns []string, key func(T) []string, options ...dataloadg
 }
 
 func LoaderCtxs[T any](columns []string, key func(context.Context, T) []string, options ...dataloadgen.Option) *dataloadgen.Loader[string, T] {
-	return dataloadgen.NewLoader(func(ctx context.Context, keys []string) ([]T, []error) {
-		db := gorm.G[T](For(ctx)).Scopes()
+	return dataloadgen.NewLoader(loadersFetch(columns, key), options...)
+}
 
+func loadersFetch[T any](columns []string, key func(context.Context, T) []string) func(context.Context, []string) ([]T, []error) {
+	return func(ctx context.Context, keys []string) ([]T, []error) {
 		rs := make([]T, len(keys))
 		es := make([]error, len(keys))
+		gdb, err := TryFor(ctx)
+		if err != nil {
+			for i := range keys {
+				es[i] = err
+			}
+			return rs, es
+		}
+		db := gorm.G[T](gdb).Scopes()
 
 		css := make([][]string, len(columns))
 
@@ -125,6 +161,16 @@ func LoaderCtxs[T any](columns []string, key func(context.Context, T) []string,
 		for _, w := range wheres {
 			db = db.Where(w.Query, w.Args...)
 		}
+		expr, err := ctxFilterFor[T](ctx, gdb)
+		if err != nil {
+			for i := range keys {
+				es[i] = err
+			}
+			return rs, es
+		}
+		if expr != nil {
+			db = db.Where(expr)
+		}
 
 		objs, err := db.Find(ctx)
 
@@ -152,5 +198,5 @@ func LoaderCtxs[T any](columns []string, key func(context.Context, T) []string,
 			}
 		}
 		return rs, es
-	}, options...)
+	}
 }
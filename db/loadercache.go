@@ -0,0 +1,365 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzlov/utils/redis"
+	uslog "github.com/nzlov/utils/slog"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/vikstrous/dataloadgen"
+	"gorm.io/gorm"
+)
+
+type LoaderCacheConfig struct {
+	Redis       *redis.Config
+	Prefix      string
+	LRUSize     int
+	TTL         time.Duration
+	LocalTTL    time.Duration
+	NegativeTTL time.Duration
+}
+
+type LoaderCacheOption func(*LoaderCacheConfig)
+
+// WithLoaderCacheRedis 使用 Redis 作为二级缓存，键以 prefix 开头，默认只使用进程内 LRU。
+func WithLoaderCacheRedis(cfg *redis.Config, prefix string) LoaderCacheOption {
+	return func(c *LoaderCacheConfig) {
+		c.Redis = cfg
+		if prefix != "" {
+			c.Prefix = prefix
+		}
+	}
+}
+
+// WithLoaderCacheLRU 设置进程内 LRU 的容量，默认 10000，为 0 时不使用进程内缓存。
+func WithLoaderCacheLRU(size int) LoaderCacheOption {
+	return func(c *LoaderCacheConfig) {
+		if size >= 0 {
+			c.LRUSize = size
+		}
+	}
+}
+
+// WithLoaderCacheTTL 设置 Redis 中缓存的有效期与进程内缓存的有效期，默认 5 分钟与 1 分钟。
+func WithLoaderCacheTTL(ttl, local time.Duration) LoaderCacheOption {
+	return func(c *LoaderCacheConfig) {
+		if ttl > 0 {
+			c.TTL = ttl
+		}
+		if local > 0 {
+			c.LocalTTL = local
+		}
+	}
+}
+
+// WithLoaderCacheNegativeTTL 设置 ErrNotFound 结果的缓存时间，默认 30 秒，为 0 时不缓存。
+func WithLoaderCacheNegativeTTL(ttl time.Duration) LoaderCacheOption {
+	return func(c *LoaderCacheConfig) {
+		if ttl >= 0 {
+			c.NegativeTTL = ttl
+		}
+	}
+}
+
+// LoaderCache 为 Loader/Loaders 提供缓存，进程内 LRU 为一级，Redis 为二级，都未命中时才查询数据库。
+// 缓存按表维护版本号，表上的任何创建、更新、删除都会使该表的全部缓存失效，
+// 需要通过 NewLoaderCacheOp 注册回调；Raw/Exec 修改数据后需要手动调用 Invalidate。
+// ctx 中设置了 WhereCtx 或启用的 FilterCtx 条件以及处于 Tx 中时不使用缓存，避免未提交的数据进入缓存，
+// 租户（TenantCtx）是缓存键的一部分。
+// 值以 JSON 保存，Encrypted 等字段会以明文写入缓存。
+type LoaderCache struct {
+	cfg LoaderCacheConfig
+	lru *lru
+
+	mu   sync.Mutex
+	gens map[string]int64 // 未使用 Redis 时的表版本号
+}
+
+func NewLoaderCache(ops ...LoaderCacheOption) *LoaderCache {
+	cfg := LoaderCacheConfig{
+		Prefix:      "loader:",
+		LRUSize:     10000,
+		TTL:         5 * time.Minute,
+		LocalTTL:    time.Minute,
+		NegativeTTL: 30 * time.Second,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	if cfg.Redis == nil {
+		// 只有进程内缓存时以 TTL 为准
+		cfg.LocalTTL = cfg.TTL
+	}
+	return &LoaderCache{cfg: cfg, lru: newLRU(cfg.LRUSize), gens: map[string]int64{}}
+}
+
+func (c *LoaderCache) genKey(table string) string {
+	return c.cfg.Prefix + "gen:" + table
+}
+
+func (c *LoaderCache) gen(ctx context.Context, table string) (string, error) {
+	if c.cfg.Redis == nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return strconv.FormatInt(c.gens[table], 10), nil
+	}
+	v, err := c.cfg.Redis.Redis().Get(ctx, c.genKey(table)).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return "", err
+	}
+	if v == "" {
+		v = "0"
+	}
+	return v, nil
+}
+
+func (c *LoaderCache) bump(ctx context.Context, table string) error {
+	if c.cfg.Redis == nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.gens[table]++
+		return nil
+	}
+	return c.cfg.Redis.Redis().Incr(ctx, c.genKey(table)).Err()
+}
+
+// Invalidate 使 model 对应表的全部缓存失效。
+func (c *LoaderCache) Invalidate(ctx context.Context, model any) error {
+	db, err := TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return err
+	}
+	return c.bump(ctx, s.Table)
+}
+
+// NewLoaderCacheOp 注册创建、更新、删除后使缓存失效的回调。
+// 语句在 Tx 中执行时，除立即失效外还会在事务提交或回滚后再次失效，避免事务期间写入缓存的数据在结束后仍被读到。
+func NewLoaderCacheOp(c *LoaderCache) Option {
+	p := &loaderCachePlugin{cache: c}
+	return func(cfg *gorm.Config) {
+		if cfg.Plugins == nil {
+			cfg.Plugins = map[string]gorm.Plugin{}
+		}
+		cfg.Plugins[p.Name()] = p
+	}
+}
+
+type loaderCachePlugin struct {
+	cache *LoaderCache
+}
+
+func (p *loaderCachePlugin) Name() string {
+	return "nzlov:loader_cache"
+}
+
+func (p *loaderCachePlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("nzlov:loader_cache_create", p.invalidate),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("nzlov:loader_cache_update", p.invalidate),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("nzlov:loader_cache_delete", p.invalidate),
+	)
+}
+
+func (p *loaderCachePlugin) invalidate(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	ctx, table := db.Statement.Context, db.Statement.Schema.Table
+	bump := func(ctx context.Context) {
+		// 数据已经写入，失效失败不影响本次写入的结果，只记录日志
+		if err := p.cache.bump(ctx, table); err != nil {
+			uslog.For(ctx).Error("loader cache invalidate", "table", table, "err", err)
+		}
+	}
+	bump(ctx)
+	if InTx(ctx) {
+		OnCommit(ctx, bump)
+		OnRollback(ctx, bump)
+	}
+}
+
+// cacheFetch 在 fetch 外包装缓存，name 为加载使用的列，与表名、租户、键一起组成缓存键。
+func cacheFetch[T any](c *LoaderCache, name string, fetch func(context.Context, []string) ([]T, []error)) func(context.Context, []string) ([]T, []error) {
+	return func(ctx context.Context, keys []string) ([]T, []error) {
+		if hasCtxFilters(ctx) || InTx(ctx) {
+			return fetch(ctx, keys)
+		}
+		gdb, err := TryFor(ctx)
+		if err != nil {
+			return fetch(ctx, keys)
+		}
+		s, err := parseSchema(gdb, new(T))
+		if err != nil {
+			return fetch(ctx, keys)
+		}
+		gen, err := c.gen(ctx, s.Table)
+		if err != nil {
+			uslog.For(ctx).Warn("loader cache unavailable", "table", s.Table, "err", err)
+			return fetch(ctx, keys)
+		}
+		tenant, _ := TenantFrom(ctx)
+		base := c.cfg.Prefix + s.Table + ":" + gen + ":" + name + ":" + tenant + ":"
+
+		rs := make([]T, len(keys))
+		es := make([]error, len(keys))
+		decode := func(i int, data []byte) bool {
+			if len(data) == 0 {
+				es[i] = fmt.Errorf("%w: %s", ErrNotFound, strings.Split(keys[i], "@nzlov@"))
+				return true
+			}
+			return json.Unmarshal(data, &rs[i]) == nil
+		}
+
+		missing := []int{}
+		for i, k := range keys {
+			if data, ok := c.lru.get(base + k); ok && decode(i, data) {
+				continue
+			}
+			missing = append(missing, i)
+		}
+
+		if c.cfg.Redis != nil && len(missing) > 0 {
+			cks := make([]string, len(missing))
+			for j, i := range missing {
+				cks[j] = base + keys[i]
+			}
+			vals, err := c.cfg.Redis.Redis().MGet(ctx, cks...).Result()
+			if err != nil {
+				uslog.For(ctx).Warn("loader cache unavailable", "table", s.Table, "err", err)
+			} else {
+				rest := missing[:0]
+				for j, i := range missing {
+					if v, ok := vals[j].(string); ok && decode(i, []byte(v)) {
+						c.lru.set(cks[j], []byte(v), c.cfg.LocalTTL)
+						continue
+					}
+					rest = append(rest, i)
+				}
+				missing = rest
+			}
+		}
+		if len(missing) == 0 {
+			return rs, es
+		}
+
+		mk := make([]string, len(missing))
+		for j, i := range missing {
+			mk[j] = keys[i]
+		}
+		mrs, mes := fetch(ctx, mk)
+
+		type entry struct {
+			key  string
+			data []byte
+			ttl  time.Duration
+		}
+		entries := []entry{}
+		for j, i := range missing {
+			rs[i], es[i] = mrs[j], mes[j]
+			switch {
+			case mes[j] == nil:
+				data, err := json.Marshal(mrs[j])
+				if err != nil {
+					continue
+				}
+				entries = append(entries, entry{key: base + keys[i], data: data, ttl: c.cfg.TTL})
+			case errors.Is(mes[j], ErrNotFound) && c.cfg.NegativeTTL > 0:
+				entries = append(entries, entry{key: base + keys[i], data: []byte{}, ttl: c.cfg.NegativeTTL})
+			}
+		}
+		for _, e := range entries {
+			c.lru.set(e.key, e.data, min(e.ttl, c.cfg.LocalTTL))
+		}
+		if c.cfg.Redis != nil && len(entries) > 0 {
+			pipe := c.cfg.Redis.Redis().Pipeline()
+			for _, e := range entries {
+				pipe.Set(ctx, e.key, e.data, e.ttl)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				uslog.For(ctx).Warn("loader cache write", "table", s.Table, "err", err)
+			}
+		}
+		return rs, es
+	}
+}
+
+// CachedLoader 与 Loader 相同，但先从 c 中读取。
+func CachedLoader[T any](c *LoaderCache, column string, key func(T) string, options ...dataloadgen.Option) *dataloadgen.Loader[string, T] {
+	return dataloadgen.NewLoader(cacheFetch(c, column, loaderFetch(column, func(ctx context.Context, t T) string {
+		return key(t)
+	})), options...)
+}
+
+// CachedLoaders 与 Loaders 相同，但先从 c 中读取。
+func CachedLoaders[T any](c *LoaderCache, columns []string, key func(T) []string, options ...dataloadgen.Option) *dataloadgen.Loader[string, T] {
+	return dataloadgen.NewLoader(cacheFetch(c, strings.Join(columns, ","), loadersFetch(columns, func(ctx context.Context, t T) []string {
+		return key(t)
+	})), options...)
+}
+
+type lruEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+// lru 进程内缓存，容量为 0 时不缓存。
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	list  *list.List
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, list: list.New(), items: map[string]*list.Element{}}
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	v := e.Value.(*lruEntry)
+	if time.Now().After(v.expires) {
+		l.list.Remove(e)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.list.MoveToFront(e)
+	return v.data, true
+}
+
+func (l *lru) set(key string, data []byte, ttl time.Duration) {
+	if l.size <= 0 || ttl <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		e.Value = &lruEntry{key: key, data: data, expires: time.Now().Add(ttl)}
+		l.list.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.list.PushFront(&lruEntry{key: key, data: data, expires: time.Now().Add(ttl)})
+	for l.list.Len() > l.size {
+		e := l.list.Back()
+		l.list.Remove(e)
+		delete(l.items, e.Value.(*lruEntry).key)
+	}
+}
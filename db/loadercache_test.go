@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// TestLoaderCache 确认命中缓存时不查询数据库，写入后缓存失效，并缓存 ErrNotFound。
+func TestLoaderCache(t *testing.T) {
+	cache := NewLoaderCache()
+	db := openTestDB(t, NewLoaderCacheOp(cache))
+	seedTestModels(t, db, 3)
+	ctx := testCtx(db)
+
+	queries := atomic.Int64{}
+	db.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) { queries.Add(1) })
+
+	load := func(name string) (dbAllTestModel, error) {
+		// 每次新建 Loader，排除 dataloader 自身的请求内缓存
+		return CachedLoader(cache, "name", func(v dbAllTestModel) string { return v.Name }).Load(ctx, name)
+	}
+
+	if _, err := load("item-1"); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	v, err := load("item-1")
+	if err != nil || v.Name != "item-1" || queries.Load() != 1 {
+		t.Fatalf("第二次加载应命中缓存: %+v %v 查询次数 %d", v, err, queries.Load())
+	}
+
+	if _, err := load("new"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不存在的键应返回 ErrNotFound: %v", err)
+	}
+	if _, err := load("new"); !errors.Is(err, ErrNotFound) || queries.Load() != 2 {
+		t.Fatalf("ErrNotFound 应被缓存: %v 查询次数 %d", err, queries.Load())
+	}
+
+	if err := For(ctx).Create(&dbAllTestModel{Name: "new"}).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if v, err := load("new"); err != nil || v.Name != "new" {
+		t.Fatalf("写入后缓存应失效: %+v %v", v, err)
+	}
+
+	if err := For(ctx).Model(&dbAllTestModel{}).Where("name = ?", "item-1").Update("name", "renamed").Error; err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if _, err := load("item-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("更新后不应读到旧缓存: %v", err)
+	}
+
+	// 带有上下文条件时不使用缓存
+	before := queries.Load()
+	wctx := WhereCtx(ctx, "id > ?", 0)
+	CachedLoader(cache, "name", func(v dbAllTestModel) string { return v.Name }).Load(wctx, "new")
+	CachedLoader(cache, "name", func(v dbAllTestModel) string { return v.Name }).Load(wctx, "new")
+	if queries.Load() != before+2 {
+		t.Fatalf("带上下文条件时不应使用缓存: %d", queries.Load()-before)
+	}
+}
+
+// TestLoaderCacheRollback 确认事务中读到的未提交数据不会进入缓存，回滚后仍读到已提交的状态。
+func TestLoaderCacheRollback(t *testing.T) {
+	cache := NewLoaderCache()
+	db := openTestDB(t, NewLoaderCacheOp(cache))
+	seedTestModels(t, db, 1)
+	ctx := testCtx(db)
+
+	load := func(ctx context.Context, name string) (dbAllTestModel, error) {
+		return CachedLoader(cache, "name", func(v dbAllTestModel) string { return v.Name }).Load(ctx, name)
+	}
+	if _, err := load(ctx, "tx"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不存在的键应返回 ErrNotFound: %v", err)
+	}
+
+	rollback := errors.New("rollback")
+	err := Tx(ctx, func(ctx context.Context) error {
+		if err := For(ctx).Create(&dbAllTestModel{Name: "tx"}).Error; err != nil {
+			return err
+		}
+		if err := For(ctx).Model(&dbAllTestModel{}).Where("name = ?", "item-1").Update("name", "renamed").Error; err != nil {
+			return err
+		}
+		if v, err := load(ctx, "tx"); err != nil || v.Name != "tx" {
+			t.Fatalf("事务内应读到未提交的数据: %+v %v", v, err)
+		}
+		if _, err := load(ctx, "item-1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("事务内不应读到更新前的数据: %v", err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("事务应回滚: %v", err)
+	}
+
+	if _, err := load(ctx, "tx"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("回滚后不应读到未提交的数据: %v", err)
+	}
+	if v, err := load(ctx, "item-1"); err != nil || v.Name != "item-1" {
+		t.Fatalf("回滚后应读到已提交的数据: %+v %v", v, err)
+	}
+}
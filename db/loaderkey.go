@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vikstrous/dataloadgen"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KeyLoader 与 Loader 相同，但键使用列的原始类型（如 uint 主键），不需要转换为字符串。
+func KeyLoader[K comparable, T any](column string, key func(T) K, options ...dataloadgen.Option) *dataloadgen.Loader[K, T] {
+	return KeyLoaderCtx(column, func(ctx context.Context, t T) K {
+		return key(t)
+	}, options...)
+}
+
+func KeyLoaderCtx[K comparable, T any](column string, key func(context.Context, T) K, options ...dataloadgen.Option) *dataloadgen.Loader[K, T] {
+	return dataloadgen.NewLoader(func(ctx context.Context, keys []K) ([]T, []error) {
+		return loadByKeys(ctx, keys, key, func(db *gorm.DB) *gorm.DB {
+			return db.Where(column+" in (?)", keys)
+		})
+	}, options...)
+}
+
+// CompositeLoader 按多列组合键加载，K 为结构体，其字段按顺序对应 columns。
+// 与 Loaders 不同，查询条件为精确的元组匹配：Postgres/MySQL 使用 (a, b) IN ((?, ?), ...)，
+// SQLite 不支持元组 IN 列表，使用 (a = ? AND b = ?) OR ... 代替。
+// K 不是字段数与 columns 相同的结构体时 panic。
+func CompositeLoader[K comparable, T any](columns []string, key func(T) K, options ...dataloadgen.Option) *dataloadgen.Loader[K, T] {
+	return CompositeLoaderCtx(columns, func(ctx context.Context, t T) K {
+		return key(t)
+	}, options...)
+}
+
+func CompositeLoaderCtx[K comparable, T any](columns []string, key func(context.Context, T) K, options ...dataloadgen.Option) *dataloadgen.Loader[K, T] {
+	kt := reflect.TypeOf((*K)(nil)).Elem()
+	if kt.Kind() != reflect.Struct || kt.NumField() != len(columns) {
+		panic(fmt.Sprintf("db: composite key %s must be a struct with %d fields", kt, len(columns)))
+	}
+	return dataloadgen.NewLoader(func(ctx context.Context, keys []K) ([]T, []error) {
+		tuples := make([][]any, len(keys))
+		for i, k := range keys {
+			rv := reflect.ValueOf(k)
+			tuples[i] = make([]any, len(columns))
+			for j := range columns {
+				tuples[i][j] = rv.Field(j).Interface()
+			}
+		}
+		return loadByKeys(ctx, keys, key, func(db *gorm.DB) *gorm.DB {
+			return db.Where(tupleIn(db, columns, tuples))
+		})
+	}, options...)
+}
+
+// tupleIn 生成多列组合匹配条件。
+func tupleIn(db *gorm.DB, columns []string, tuples [][]any) clause.Expression {
+	if db.Dialector.Name() == "sqlite" {
+		ors := make([]clause.Expression, len(tuples))
+		for i, t := range tuples {
+			ands := make([]clause.Expression, len(columns))
+			for j, c := range columns {
+				ands[j] = clause.Eq{Column: clause.Column{Name: c}, Value: t[j]}
+			}
+			ors[i] = clause.And(ands...)
+		}
+		return clause.Or(ors...)
+	}
+	return clause.Expr{SQL: "(" + strings.Join(columns, ",") + ") IN ?", Vars: []any{tuples}}
+}
+
+// loadByKeys 查询并按 key 将结果对应到 keys，附加 WhereCtx 与 FilterCtx 设置的上下文条件。
+func loadByKeys[K comparable, T any](ctx context.Context, keys []K, key func(context.Context, T) K, where func(*gorm.DB) *gorm.DB) ([]T, []error) {
+	rs := make([]T, len(keys))
+	es := make([]error, len(keys))
+	fail := func(err error) ([]T, []error) {
+		for i := range keys {
+			es[i] = err
+		}
+		return rs, es
+	}
+
+	gdb, err := TryFor(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	s, err := parseSchema(gdb, new(T))
+	if err != nil {
+		return fail(err)
+	}
+	db, err := applyCtxWheres(ctx, where(gdb.Model(new(T))), s)
+	if err != nil {
+		return fail(err)
+	}
+	objs := []T{}
+	if err := db.Find(&objs).Error; err != nil {
+		return fail(err)
+	}
+
+	rm := make(map[K]T, len(objs))
+	for _, v := range objs {
+		rm[key(ctx, v)] = v
+	}
+	var ok bool
+	for i, k := range keys {
+		rs[i], ok = rm[k]
+		if !ok {
+			es[i] = fmt.Errorf("%w: %v", ErrNotFound, k)
+		}
+	}
+	return rs, es
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vikstrous/dataloadgen"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type loaderKeyTestModel struct {
+	Model
+	Org  string
+	Code string
+}
+
+type orgCode struct {
+	Org  string
+	Code string
+}
+
+// TestCompositeLoader 确认组合键精确匹配，不会因为各列分别 IN 而匹配到交叉组合。
+func TestCompositeLoader(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&loaderKeyTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	objs := []loaderKeyTestModel{{Org: "a", Code: "1"}, {Org: "b", Code: "2"}, {Org: "a@nzlov@x", Code: "3"}}
+	if err := db.Create(&objs).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	ctx := testCtx(db)
+
+	l := CompositeLoader([]string{"org", "code"}, func(v loaderKeyTestModel) orgCode {
+		return orgCode{v.Org, v.Code}
+	})
+	rs, err := l.LoadAll(ctx, []orgCode{{"a", "1"}, {"a@nzlov@x", "3"}, {"a", "2"}})
+	if rs[0].ID != objs[0].ID || rs[1].ID != objs[2].ID {
+		t.Fatalf("组合键加载不正确: %+v", rs)
+	}
+	var errs dataloadgen.ErrorSlice
+	if !errors.As(err, &errs) || len(errs) != 3 || errs[0] != nil || !errors.Is(errs[2], ErrNotFound) {
+		t.Fatalf("交叉组合 (a, 2) 不应匹配: %v", err)
+	}
+
+	byID := KeyLoader("id", func(v loaderKeyTestModel) uint { return v.ID })
+	v, err := byID.Load(ctx, objs[1].ID)
+	if err != nil || v.Code != "2" {
+		t.Fatalf("类型化键加载不正确: %+v %v", v, err)
+	}
+}
+
+// TestTupleInDialect 确认 Postgres 使用元组 IN。
+func TestTupleInDialect(t *testing.T) {
+	pg, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("打开 postgres 失败: %v", err)
+	}
+	sql := pg.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where(tupleIn(tx, []string{"org", "code"}, [][]any{{"a", "1"}, {"b", "2"}})).Find(&[]loaderKeyTestModel{})
+	})
+	want := `SELECT * FROM "loader_key_test_models" WHERE (org,code) IN (('a','1'),('b','2')) AND "loader_key_test_models"."deleted_at" IS NULL`
+	if sql != want {
+		t.Fatalf("生成的 SQL 不正确:\n%s\n%s", sql, want)
+	}
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/vikstrous/dataloadgen"
+)
+
+type LoaderManyConfig struct {
+	Sorts   []Sort
+	Limit   int
+	Options []dataloadgen.Option
+}
+
+type LoaderManyOption func(*LoaderManyConfig)
+
+// WithLoaderManyOrder 设置每个键下记录的排序，默认按主键升序。
+func WithLoaderManyOrder(sorts ...Sort) LoaderManyOption {
+	return func(cfg *LoaderManyConfig) {
+		cfg.Sorts = sorts
+	}
+}
+
+// WithLoaderManyLimit 限制每个键最多返回的记录数，使用 ROW_NUMBER() 窗口函数实现（MySQL 需要 8.0 以上）。
+func WithLoaderManyLimit(limit int) LoaderManyOption {
+	return func(cfg *LoaderManyConfig) {
+		cfg.Limit = limit
+	}
+}
+
+// WithLoaderManyOptions 透传 dataloadgen 的参数。
+func WithLoaderManyOptions(options ...dataloadgen.Option) LoaderManyOption {
+	return func(cfg *LoaderManyConfig) {
+		cfg.Options = append(cfg.Options, options...)
+	}
+}
+
+// LoaderMany 一对多加载，每个键返回 column 等于该键的全部记录，没有记录时返回空切片而不是 ErrNotFound。
+// 与 Loader 相同会附加 WhereCtx 与 FilterCtx 设置的上下文条件。
+func LoaderMany[T any](column string, key func(T) string, ops ...LoaderManyOption) *dataloadgen.Loader[string, []T] {
+	return LoaderManyCtx(column, func(ctx context.Context, t T) string {
+		return key(t)
+	}, ops...)
+}
+
+func LoaderManyCtx[T any](column string, key func(context.Context, T) string, ops ...LoaderManyOption) *dataloadgen.Loader[string, []T] {
+	cfg := LoaderManyConfig{}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	return dataloadgen.NewLoader(func(ctx context.Context, keys []string) ([][]T, []error) {
+		rs := make([][]T, len(keys))
+		es := make([]error, len(keys))
+		fail := func(err error) ([][]T, []error) {
+			for i := range keys {
+				es[i] = err
+			}
+			return rs, es
+		}
+
+		gdb, err := TryFor(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		s, err := parseSchema(gdb, new(T))
+		if err != nil {
+			return fail(err)
+		}
+
+		// 排序列来自 schema，最后追加主键保证顺序稳定
+		order := []string{}
+		for _, v := range cfg.Sorts {
+			c, err := lookupColumn(s, v.Field)
+			if err != nil {
+				return fail(err)
+			}
+			if v.Desc {
+				c += " DESC"
+			}
+			order = append(order, s.Table+"."+c)
+		}
+		for _, f := range s.PrimaryFields {
+			order = append(order, s.Table+"."+f.DBName)
+		}
+
+		db, err := applyCtxWheres(ctx, gdb.Model(new(T)).Where(column+" in (?)", keys), s)
+		if err != nil {
+			return fail(err)
+		}
+
+		objs := []T{}
+		if cfg.Limit > 0 {
+			rn := "ROW_NUMBER() OVER (PARTITION BY " + column + " ORDER BY " + strings.Join(order, ",") + ") AS nzlov_rn"
+			inner := db.Select(s.Table + ".*, " + rn)
+			err = gdb.Table("(?) AS "+s.Table, inner).
+				Where("nzlov_rn <= ?", cfg.Limit).
+				Order(strings.Join(order, ",")).
+				Find(&objs).Error
+		} else {
+			err = db.Order(strings.Join(order, ",")).Find(&objs).Error
+		}
+		if err != nil {
+			return fail(err)
+		}
+
+		idx := make(map[string]int, len(keys))
+		for i, k := range keys {
+			idx[k] = i
+			rs[i] = []T{}
+		}
+		for _, v := range objs {
+			if i, ok := idx[key(ctx, v)]; ok {
+				rs[i] = append(rs[i], v)
+			}
+		}
+		return rs, es
+	}, cfg.Options...)
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"testing"
+)
+
+type loaderManyTestModel struct {
+	Model
+	UserID string
+	Amount int
+}
+
+// TestLoaderMany 确认按键分组返回切片，支持排序与每个键的数量限制，没有记录的键返回空切片。
+func TestLoaderMany(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&loaderManyTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	objs := []loaderManyTestModel{
+		{UserID: "u1", Amount: 10}, {UserID: "u1", Amount: 30}, {UserID: "u1", Amount: 20},
+		{UserID: "u2", Amount: 5}, {UserID: "u2", Amount: 7},
+	}
+	if err := db.Create(&objs).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	db.Delete(&objs[4])
+	ctx := testCtx(db)
+	key := func(v loaderManyTestModel) string { return v.UserID }
+
+	rs, err := LoaderMany("user_id", key).LoadAll(ctx, []string{"u1", "u2", "u3"})
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if len(rs[0]) != 3 || rs[0][0].Amount != 10 || len(rs[1]) != 1 || rs[2] == nil || len(rs[2]) != 0 {
+		t.Fatalf("分组结果不正确: %+v", rs)
+	}
+
+	rs, err = LoaderMany("user_id", key,
+		WithLoaderManyOrder(Sort{Field: "amount", Desc: true}),
+		WithLoaderManyLimit(2),
+	).LoadAll(ctx, []string{"u1", "u2"})
+	if err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if len(rs[0]) != 2 || rs[0][0].Amount != 30 || rs[0][1].Amount != 20 || len(rs[1]) != 1 || rs[1][0].Amount != 5 {
+		t.Fatalf("排序或限制不正确: %+v", rs)
+	}
+
+	rs, err = LoaderMany("user_id", key).LoadAll(WhereCtx(ctx, "amount > ?", 15), []string{"u1"})
+	if err != nil || len(rs[0]) != 2 {
+		t.Fatalf("上下文条件未生效: %+v %v", rs, err)
+	}
+}
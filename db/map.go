@@ -1,3 +1,28 @@
 package db
 
+import (
+	"database/sql/driver"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Map 以 JSON 对象保存在单个列中，列类型见 jsonDBDataType。
+// 可使用 JSONHasKey、JSONPathEq 按键查询。
 type Map[K comparable, T any] map[K]T
+
+func (m Map[K, T]) Value() (driver.Value, error) {
+	return jsonValue(m)
+}
+
+func (m *Map[K, T]) Scan(v any) error {
+	return jsonScan(m, v)
+}
+
+func (Map[K, T]) GormDataType() string {
+	return "json"
+}
+
+func (Map[K, T]) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	return jsonDBDataType(db)
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Null 可为 NULL 的列，与 sql.Null 相同，但 JSON 序列化时无效值输出 null，
+// 并且 T 为 Decimal 等自定义类型时沿用其数据库类型。
+type Null[T any] struct {
+	V     T
+	Valid bool
+}
+
+func NewNull[T any](v T) Null[T] {
+	return Null[T]{V: v, Valid: true}
+}
+
+// NullPtr 从指针构造，p 为 nil 时为 NULL。
+func NullPtr[T any](p *T) Null[T] {
+	if p == nil {
+		return Null[T]{}
+	}
+	return NewNull(*p)
+}
+
+// Ptr 返回值的指针，NULL 时返回 nil。
+func (n Null[T]) Ptr() *T {
+	if !n.Valid {
+		return nil
+	}
+	v := n.V
+	return &v
+}
+
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	if vr, ok := any(n.V).(driver.Valuer); ok {
+		return vr.Value()
+	}
+	return driver.DefaultParameterConverter.ConvertValue(n.V)
+}
+
+func (n *Null[T]) Scan(v any) error {
+	sn := sql.Null[T]{}
+	if err := sn.Scan(v); err != nil {
+		return err
+	}
+	n.V, n.Valid = sn.V, sn.Valid
+	return nil
+}
+
+func (Null[T]) GormDataType() string {
+	var v T
+	if dt, ok := any(v).(schema.GormDataTypeInterface); ok {
+		return dt.GormDataType()
+	}
+	if dt, ok := any(&v).(schema.GormDataTypeInterface); ok {
+		return dt.GormDataType()
+	}
+
+	t := reflect.TypeOf(&v).Elem()
+	if t == reflect.TypeOf(time.Time{}) {
+		return string(schema.Time)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return string(schema.Bool)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return string(schema.Int)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return string(schema.Uint)
+	case reflect.Float32, reflect.Float64:
+		return string(schema.Float)
+	case reflect.String:
+		return string(schema.String)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return string(schema.Bytes)
+		}
+	}
+	return ""
+}
+
+// GormDBDataType 在 T 自定义了数据库类型时使用该类型，否则按 GormDataType 由方言决定。
+func (Null[T]) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	var v T
+	if dt, ok := any(&v).(interface {
+		GormDBDataType(*gorm.DB, *schema.Field) string
+	}); ok {
+		return dt.GormDBDataType(db, field)
+	}
+	return ""
+}
+
+func (n Null[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.V)
+}
+
+func (n *Null[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*n = Null[T]{}
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.V); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
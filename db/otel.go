@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	uotel "github.com/nzlov/utils/otel"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const otelPluginName = "nzlov:otel"
+
+type OtelConfig struct {
+	Tracer trace.Tracer
+	Meter  metric.Meter
+	Params bool
+}
+
+type OtelOption func(*OtelConfig)
+
+// WithOtelTracer 指定 Tracer，默认使用 otel.Tracer（未初始化时为全局 TracerProvider）。
+func WithOtelTracer(tracer trace.Tracer) OtelOption {
+	return func(cfg *OtelConfig) {
+		cfg.Tracer = tracer
+	}
+}
+
+// WithOtelMeter 指定 Meter，默认使用 otel.Meter（未初始化时为全局 MeterProvider）。
+func WithOtelMeter(meter metric.Meter) OtelOption {
+	return func(cfg *OtelConfig) {
+		cfg.Meter = meter
+	}
+}
+
+// WithOtelParams 在 db.statement 中带上参数值，默认只记录带占位符的 SQL，避免敏感数据进入追踪系统。
+func WithOtelParams() OtelOption {
+	return func(cfg *OtelConfig) {
+		cfg.Params = true
+	}
+}
+
+// NewOtelOp 注册追踪插件：每条语句一个 span（db.system、db.statement、db.operation、db.sql.table、db.rows_affected），
+// 并记录 db.client.operation.duration 耗时直方图和 db.client.errors 错误计数，ErrRecordNotFound 不算错误。
+// Tx 开启的事务会创建 db.transaction span，事务内的语句作为其子 span。
+// span 的父级来自语句的 Context，需要通过 For(ctx) 取库。
+func NewOtelOp(ops ...OtelOption) Option {
+	p := &otelPlugin{}
+	for _, op := range ops {
+		op(&p.cfg)
+	}
+	return func(cfg *gorm.Config) {
+		if cfg.Plugins == nil {
+			cfg.Plugins = map[string]gorm.Plugin{}
+		}
+		cfg.Plugins[p.Name()] = p
+	}
+}
+
+type otelPlugin struct {
+	cfg      OtelConfig
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+type otelState struct {
+	ctx       context.Context
+	span      trace.Span
+	operation string
+	begin     time.Time
+}
+
+func (p *otelPlugin) Name() string {
+	return otelPluginName
+}
+
+func (p *otelPlugin) Initialize(db *gorm.DB) error {
+	if p.cfg.Tracer == nil {
+		p.cfg.Tracer = uotel.Tracer
+	}
+	if p.cfg.Tracer == nil {
+		p.cfg.Tracer = otel.GetTracerProvider().Tracer("github.com/nzlov/utils/db")
+	}
+	if p.cfg.Meter == nil {
+		p.cfg.Meter = uotel.Meter
+	}
+	if p.cfg.Meter == nil {
+		p.cfg.Meter = otel.GetMeterProvider().Meter("github.com/nzlov/utils/db")
+	}
+	var err error
+	p.duration, err = p.cfg.Meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("数据库语句耗时"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	p.errors, err = p.cfg.Meter.Int64Counter("db.client.errors", metric.WithDescription("数据库语句错误数"))
+	if err != nil {
+		return err
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("*").Register("nzlov:otel_before_create", p.before("create")),
+		cb.Create().After("*").Register("nzlov:otel_after_create", p.after),
+		cb.Query().Before("*").Register("nzlov:otel_before_query", p.before("query")),
+		cb.Query().After("*").Register("nzlov:otel_after_query", p.after),
+		cb.Update().Before("*").Register("nzlov:otel_before_update", p.before("update")),
+		cb.Update().After("*").Register("nzlov:otel_after_update", p.after),
+		cb.Delete().Before("*").Register("nzlov:otel_before_delete", p.before("delete")),
+		cb.Delete().After("*").Register("nzlov:otel_after_delete", p.after),
+		cb.Row().Before("*").Register("nzlov:otel_before_row", p.before("row")),
+		cb.Row().After("*").Register("nzlov:otel_after_row", p.after),
+		cb.Raw().Before("*").Register("nzlov:otel_before_raw", p.before("raw")),
+		cb.Raw().After("*").Register("nzlov:otel_after_raw", p.after),
+	)
+}
+
+func dbSystem(db *gorm.DB) string {
+	if name := db.Dialector.Name(); name != "postgres" {
+		return name
+	}
+	return "postgresql"
+}
+
+func (p *otelPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		stmt := db.Statement
+		ctx, span := p.cfg.Tracer.Start(stmt.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", dbSystem(db)),
+				attribute.String("db.operation", operation),
+			))
+		stmt.Settings.Store(otelPluginName, &otelState{ctx: stmt.Context, span: span, operation: operation, begin: time.Now()})
+		// 回调中再执行的语句（如审计日志）成为当前语句的子 span
+		stmt.Context = ctx
+	}
+}
+
+func (p *otelPlugin) after(db *gorm.DB) {
+	stmt := db.Statement
+	v, ok := stmt.Settings.LoadAndDelete(otelPluginName)
+	if !ok {
+		return
+	}
+	state := v.(*otelState)
+	stmt.Context = state.ctx
+
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", dbSystem(db)),
+		attribute.String("db.operation", state.operation),
+	}
+	if stmt.Table != "" {
+		attrs = append(attrs, attribute.String("db.sql.table", stmt.Table))
+	}
+
+	sql := stmt.SQL.String()
+	if p.cfg.Params {
+		sql = db.Dialector.Explain(sql, stmt.Vars...)
+	}
+	span := state.span
+	span.SetAttributes(append(attrs,
+		attribute.String("db.statement", sql),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)...)
+
+	err := db.Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		p.errors.Add(state.ctx, 1, metric.WithAttributes(attrs...))
+	}
+	span.End()
+	p.duration.Record(state.ctx, time.Since(state.begin).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// startTxSpan 为 Tx 开启事务 span，没有注册追踪插件时返回原 ctx。
+func startTxSpan(ctx context.Context, db *gorm.DB) (context.Context, func(error)) {
+	p, ok := db.Config.Plugins[otelPluginName].(*otelPlugin)
+	if !ok {
+		return ctx, func(error) {}
+	}
+	ctx, span := p.cfg.Tracer.Start(ctx, "db.transaction",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", dbSystem(db)),
+			attribute.Int("db.transaction.depth", TxDepth(ctx)+1),
+		))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestOtelPlugin 确认每条语句生成 span 并挂在事务 span 下，默认不记录参数值，错误计入计数器。
+func TestOtelPlugin(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	db := openTestDB(t, NewOtelOp(WithOtelTracer(tp.Tracer("test")), WithOtelMeter(mp.Meter("test"))))
+	ctx := testCtx(db)
+	err := Tx(ctx, func(ctx context.Context) error {
+		return For(ctx).Create(&dbAllTestModel{Name: "secret-name"}).Error
+	})
+	if err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := For(ctx).Exec("SELECT * FROM not_exists").Error; err == nil {
+		t.Fatal("查询不存在的表应返回错误")
+	}
+
+	var txSpan, createSpan, rawSpan sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		switch s.Name() {
+		case "db.transaction":
+			txSpan = s
+		case "gorm.create":
+			createSpan = s
+		case "gorm.raw":
+			rawSpan = s
+		}
+	}
+	if txSpan == nil || createSpan == nil || rawSpan == nil {
+		t.Fatalf("缺少 span: %v", sr.Ended())
+	}
+	if createSpan.Parent().SpanID() != txSpan.SpanContext().SpanID() {
+		t.Fatal("事务内语句应为事务 span 的子 span")
+	}
+	for _, kv := range createSpan.Attributes() {
+		if kv.Key == "db.statement" && strings.Contains(kv.Value.AsString(), "secret-name") {
+			t.Fatalf("默认不应记录参数: %s", kv.Value.AsString())
+		}
+		if kv.Key == "db.rows_affected" && kv.Value.AsInt64() != 1 {
+			t.Fatalf("影响行数不正确: %d", kv.Value.AsInt64())
+		}
+	}
+	if rawSpan.Status().Code != codes.Error {
+		t.Fatalf("错误语句的 span 状态应为 Error: %v", rawSpan.Status())
+	}
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("采集指标失败: %v", err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "db.client.errors" {
+				if len(sum.DataPoints) != 1 || sum.DataPoints[0].Value != 1 {
+					t.Fatalf("错误计数不正确: %+v", sum.DataPoints)
+				}
+			}
+		}
+	}
+	if !found["db.client.operation.duration"] || !found["db.client.errors"] {
+		t.Fatalf("缺少指标: %v", found)
+	}
+}
+
+// TestOtelPluginParams 确认 WithOtelParams 会记录参数值。
+func TestOtelPluginParams(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	db := openTestDB(t, NewOtelOp(WithOtelTracer(tp.Tracer("test")), WithOtelParams()))
+	if err := For(testCtx(db)).Create(&dbAllTestModel{Name: "visible-name"}).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	for _, s := range sr.Ended() {
+		for _, kv := range s.Attributes() {
+			if kv.Key == "db.statement" && strings.Contains(kv.Value.AsString(), "visible-name") {
+				return
+			}
+		}
+	}
+	t.Fatal("开启 WithOtelParams 后应记录参数")
+}
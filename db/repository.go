@@ -0,0 +1,322 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrConflict     = errors.New("version conflict")
+	ErrInvalidField = errors.New("invalid field")
+	ErrInvalidOp    = errors.New("invalid operator")
+)
+
+const (
+	OpEq        = "="
+	OpNe        = "!="
+	OpGt        = ">"
+	OpGte       = ">="
+	OpLt        = "<"
+	OpLte       = "<="
+	OpIn        = "in"
+	OpNotIn     = "not in"
+	OpLike      = "like"
+	OpIsNull    = "is null"
+	OpIsNotNull = "is not null"
+)
+
+// Filter 描述一个字段条件，Field 可以是结构体字段名或数据库列名，会在执行前与模型 schema 校验，
+// 因此可以直接接收来自接口的参数。
+type Filter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+type Sort struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// Query 是 Repository.List 的结构化查询条件，多个 Filter 之间为 AND。
+type Query struct {
+	Filters     []Filter `json:"filters"`
+	Sorts       []Sort   `json:"sorts"`
+	Offset      int      `json:"offset"`
+	Limit       int      `json:"limit"`
+	WithDeleted bool     `json:"withDeleted"`
+	OnlyDeleted bool     `json:"onlyDeleted"`
+	Count       bool     `json:"count"`
+}
+
+func parseSchema(db *gorm.DB, model any) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+func lookupColumn(s *schema.Schema, name string) (string, error) {
+	f := s.LookUpField(name)
+	if f == nil || f.DBName == "" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidField, name)
+	}
+	return f.DBName, nil
+}
+
+// buildFilter 将 Filter 转换为带占位符的条件，列名来自 schema，值全部走参数绑定。
+func buildFilter(s *schema.Schema, f Filter) (string, []any, error) {
+	column, err := lookupColumn(s, f.Field)
+	if err != nil {
+		return "", nil, err
+	}
+	column = s.Table + "." + column
+	switch op := strings.ToLower(f.Op); op {
+	case OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike:
+		if op == OpNe {
+			op = "<>"
+		}
+		return column + " " + op + " ?", []any{f.Value}, nil
+	case OpIn, OpNotIn:
+		return column + " " + op + " (?)", []any{f.Value}, nil
+	case OpIsNull, OpIsNotNull:
+		return column + " " + op, nil, nil
+	default:
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidOp, f.Op)
+	}
+}
+
+type RepositoryConfig struct {
+	VersionField string
+}
+
+type RepositoryOption func(*RepositoryConfig)
+
+// WithRepositoryVersion 启用乐观锁，field 为整数版本号字段，Update 时版本不一致返回 ErrConflict。
+func WithRepositoryVersion(field string) RepositoryOption {
+	return func(cfg *RepositoryConfig) {
+		cfg.VersionField = field
+	}
+}
+
+// Repository 为嵌入 Model 的类型提供通用增删改查，所有查询都通过 For(ctx) 取库，
+// 并附加 WhereCtx 与 FilterCtx 设置的上下文条件。
+type Repository[T DBModelID] struct {
+	cfg RepositoryConfig
+}
+
+func NewRepository[T DBModelID](ops ...RepositoryOption) *Repository[T] {
+	cfg := RepositoryConfig{}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	return &Repository[T]{cfg: cfg}
+}
+
+func (r *Repository[T]) db(ctx context.Context) (*gorm.DB, *schema.Schema, error) {
+	db, err := TryFor(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := parseSchema(db, new(T))
+	if err != nil {
+		return nil, nil, err
+	}
+	db = db.WithContext(ctx).Model(new(T))
+	if db, err = applyCtxWheres(ctx, db, s); err != nil {
+		return nil, nil, err
+	}
+	return db, s, nil
+}
+
+func notFound(err error, id uint) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return err
+}
+
+func (r *Repository[T]) Create(ctx context.Context, obj *T) error {
+	db, err := TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).Create(obj).Error
+}
+
+// Get 按主键查询，不存在时返回 ErrNotFound。
+func (r *Repository[T]) Get(ctx context.Context, id uint) (T, error) {
+	var obj T
+	db, _, err := r.db(ctx)
+	if err != nil {
+		return obj, err
+	}
+	return obj, notFound(db.First(&obj, id).Error, id)
+}
+
+// Update 更新 obj 的全部字段（包括零值）。
+// 启用乐观锁时以 obj 当前版本号为条件，更新成功后 obj 的版本号加一。
+func (r *Repository[T]) Update(ctx context.Context, obj *T) error {
+	db, s, err := r.db(ctx)
+	if err != nil {
+		return err
+	}
+	db = db.Where("id = ?", (*obj).GetID()).Select("*").Omit("id", "created_at", "deleted_at")
+
+	if r.cfg.VersionField == "" {
+		rs := db.Updates(obj)
+		if rs.Error == nil && rs.RowsAffected == 0 {
+			return fmt.Errorf("%w: %d", ErrNotFound, (*obj).GetID())
+		}
+		return rs.Error
+	}
+
+	f := s.LookUpField(r.cfg.VersionField)
+	if f == nil {
+		return fmt.Errorf("%w: %s", ErrInvalidField, r.cfg.VersionField)
+	}
+	rv := reflect.ValueOf(obj).Elem()
+	old, _ := f.ValueOf(ctx, rv)
+	var version int64
+	switch ov := reflect.ValueOf(old); {
+	case ov.CanInt():
+		version = ov.Int()
+	case ov.CanUint():
+		version = int64(ov.Uint())
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidField, r.cfg.VersionField)
+	}
+	if err := f.Set(ctx, rv, version+1); err != nil {
+		return err
+	}
+	rs := db.Where(f.DBName+" = ?", version).Updates(obj)
+	if rs.Error == nil && rs.RowsAffected == 0 {
+		rs.Error = fmt.Errorf("%w: %d@%d", ErrConflict, (*obj).GetID(), version)
+	}
+	if rs.Error != nil {
+		f.Set(ctx, rv, version)
+	}
+	return rs.Error
+}
+
+// UpdateFields 只更新 fields 中的字段，key 可以是字段名或列名。
+// 启用乐观锁时 version 为期望的当前版本号，否则忽略。
+func (r *Repository[T]) UpdateFields(ctx context.Context, id uint, version int64, fields map[string]any) error {
+	db, s, err := r.db(ctx)
+	if err != nil {
+		return err
+	}
+	updates := make(map[string]any, len(fields)+1)
+	for k, v := range fields {
+		column, err := lookupColumn(s, k)
+		if err != nil {
+			return err
+		}
+		updates[column] = v
+	}
+
+	db = db.Where("id = ?", id)
+	if r.cfg.VersionField != "" {
+		column, err := lookupColumn(s, r.cfg.VersionField)
+		if err != nil {
+			return err
+		}
+		db = db.Where(column+" = ?", version)
+		updates[column] = version + 1
+	}
+
+	rs := db.Updates(updates)
+	if rs.Error == nil && rs.RowsAffected == 0 {
+		if r.cfg.VersionField != "" {
+			return fmt.Errorf("%w: %d@%d", ErrConflict, id, version)
+		}
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return rs.Error
+}
+
+// Delete 软删除。
+func (r *Repository[T]) Delete(ctx context.Context, id uint) error {
+	db, _, err := r.db(ctx)
+	if err != nil {
+		return err
+	}
+	rs := db.Delete(new(T), id)
+	if rs.Error == nil && rs.RowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return rs.Error
+}
+
+// Restore 恢复被软删除的记录。
+func (r *Repository[T]) Restore(ctx context.Context, id uint) error {
+	db, _, err := r.db(ctx)
+	if err != nil {
+		return err
+	}
+	rs := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	if rs.Error == nil && rs.RowsAffected == 0 {
+		return fmt.Errorf("%w: %d", ErrNotFound, id)
+	}
+	return rs.Error
+}
+
+// List 按 Query 过滤、排序、分页，q.Count 为 true 时同时返回分页前的总数。
+// 默认不包含软删除记录，未指定排序时按 id 升序。
+func (r *Repository[T]) List(ctx context.Context, q Query) ([]T, int64, error) {
+	db, s, err := r.db(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if q.WithDeleted || q.OnlyDeleted {
+		db = db.Unscoped()
+	}
+	if q.OnlyDeleted {
+		db = db.Where(s.Table + ".deleted_at IS NOT NULL")
+	}
+	for _, f := range q.Filters {
+		query, args, err := buildFilter(s, f)
+		if err != nil {
+			return nil, 0, err
+		}
+		db = db.Where(query, args...)
+	}
+
+	total := int64(0)
+	if q.Count {
+		if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if len(q.Sorts) == 0 {
+		q.Sorts = []Sort{{Field: "id"}}
+	}
+	for _, v := range q.Sorts {
+		column, err := lookupColumn(s, v.Field)
+		if err != nil {
+			return nil, 0, err
+		}
+		if v.Desc {
+			column += " DESC"
+		}
+		db = db.Order(s.Table + "." + column)
+	}
+	if q.Offset > 0 {
+		db = db.Offset(q.Offset)
+	}
+	if q.Limit > 0 {
+		db = db.Limit(q.Limit)
+	}
+
+	objs := []T{}
+	return objs, total, db.Find(&objs).Error
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+type repoTestModel struct {
+	Model
+	Name    string
+	Age     int
+	Version int64
+}
+
+// TestRepositoryCRUD 覆盖增删改查与恢复的完整流程，确保软删除记录默认不会出现在列表中。
+func TestRepositoryCRUD(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&repoTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	ctx := testCtx(db)
+	repo := NewRepository[repoTestModel]()
+
+	for i, name := range []string{"a", "b", "c"} {
+		if err := repo.Create(ctx, &repoTestModel{Name: name, Age: i + 10}); err != nil {
+			t.Fatalf("创建失败: %v", err)
+		}
+	}
+
+	obj, err := repo.Get(ctx, 2)
+	if err != nil || obj.Name != "b" {
+		t.Fatalf("查询结果不正确: obj=%+v err=%v", obj, err)
+	}
+	if _, err := repo.Get(ctx, 100); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不存在的记录应返回 ErrNotFound: %v", err)
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	objs, total, err := repo.List(ctx, Query{
+		Filters: []Filter{{Field: "Age", Op: OpGte, Value: 10}},
+		Sorts:   []Sort{{Field: "name", Desc: true}},
+		Count:   true,
+	})
+	if err != nil || total != 2 || len(objs) != 2 || objs[0].Name != "c" {
+		t.Fatalf("列表结果不正确: objs=%+v total=%d err=%v", objs, total, err)
+	}
+
+	if err := repo.Restore(ctx, 1); err != nil {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	objs, _, err = repo.List(ctx, Query{Limit: 1})
+	if err != nil || len(objs) != 1 || objs[0].ID != 1 {
+		t.Fatalf("恢复后列表不正确: objs=%+v err=%v", objs, err)
+	}
+
+	if _, _, err := repo.List(ctx, Query{Filters: []Filter{{Field: "name; drop table", Op: OpEq}}}); !errors.Is(err, ErrInvalidField) {
+		t.Fatalf("非法字段应被拒绝: %v", err)
+	}
+}
+
+// TestRepositoryOptimisticLock 验证并发修改时旧版本的更新会被拒绝，避免静默覆盖他人的修改。
+func TestRepositoryOptimisticLock(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&repoTestModel{}); err != nil {
+		t.Fatalf("迁移测试表失败: %v", err)
+	}
+	ctx := testCtx(db)
+	repo := NewRepository[repoTestModel](WithRepositoryVersion("Version"))
+
+	obj := repoTestModel{Name: "a"}
+	if err := repo.Create(ctx, &obj); err != nil {
+		t.Fatalf("创建失败: %v", err)
+	}
+	stale := obj
+
+	obj.Name = "b"
+	if err := repo.Update(ctx, &obj); err != nil || obj.Version != 1 {
+		t.Fatalf("更新失败: version=%d err=%v", obj.Version, err)
+	}
+
+	stale.Name = "c"
+	if err := repo.Update(ctx, &stale); !errors.Is(err, ErrConflict) || stale.Version != 0 {
+		t.Fatalf("旧版本更新应冲突: version=%d err=%v", stale.Version, err)
+	}
+
+	if err := repo.UpdateFields(ctx, obj.ID, 1, map[string]any{"age": 20}); err != nil {
+		t.Fatalf("部分更新失败: %v", err)
+	}
+	got, _ := repo.Get(ctx, obj.ID)
+	if got.Name != "b" || got.Age != 20 || got.Version != 2 {
+		t.Fatalf("部分更新结果不正确: %+v", got)
+	}
+}
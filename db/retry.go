@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type TxRetryConfig struct {
+	Attempts   int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	Retryable  func(error) bool
+	TxOptions  *sql.TxOptions
+}
+
+type TxRetryOption func(*TxRetryConfig)
+
+// WithTxRetryAttempts 设置最大尝试次数（包含第一次）。
+func WithTxRetryAttempts(n int) TxRetryOption {
+	return func(cfg *TxRetryConfig) {
+		if n > 0 {
+			cfg.Attempts = n
+		}
+	}
+}
+
+// WithTxRetryBackoff 设置指数退避的初始与最大等待时间，实际等待会加入随机抖动避免多个事务同时重试再次冲突。
+func WithTxRetryBackoff(min, max time.Duration) TxRetryOption {
+	return func(cfg *TxRetryConfig) {
+		if min > 0 {
+			cfg.MinBackoff = min
+		}
+		if max >= cfg.MinBackoff {
+			cfg.MaxBackoff = max
+		}
+	}
+}
+
+// WithTxRetryClassifier 自定义可重试错误的判断，默认使用 IsRetryableTxError。
+func WithTxRetryClassifier(f func(error) bool) TxRetryOption {
+	return func(cfg *TxRetryConfig) {
+		if f != nil {
+			cfg.Retryable = f
+		}
+	}
+}
+
+// WithTxRetryOptions 设置事务选项，例如 sql.LevelSerializable 隔离级别。
+func WithTxRetryOptions(opts *sql.TxOptions) TxRetryOption {
+	return func(cfg *TxRetryConfig) {
+		cfg.TxOptions = opts
+	}
+}
+
+// IsRetryableTxError 判断错误是否为重新执行整个事务即可能成功的并发冲突：
+// Postgres 序列化失败(40001)与死锁(40P01)，MySQL 死锁(1213)与锁等待超时(1205)，SQLite 库忙(SQLITE_BUSY/SQLITE_LOCKED)。
+func IsRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+
+	var liteErr interface{ Code() int }
+	if errors.As(err, &liteErr) {
+		code := liteErr.Code() & 0xff
+		return code == 5 || code == 6
+	}
+
+	return false
+}
+
+// TxRetry 与 Tx 相同，但遇到可重试的并发冲突时会回滚并重新执行 f。
+// 每次重试都会开启全新的事务，上一次注册的 OnCommit 回调会被丢弃，因此 f 必须是可重入的，
+// 不应在事务外留下副作用。
+// 已处于事务中时无法单独重试嵌套事务，此时直接按 Tx 执行一次，由最外层的 TxRetry 负责重试。
+func TxRetry(ctx context.Context, f func(context.Context) error, ops ...TxRetryOption) error {
+	cfg := TxRetryConfig{
+		Attempts:   3,
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: time.Second,
+		Retryable:  IsRetryableTxError,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+
+	if ctx == nil {
+		return ErrNilContext
+	}
+
+	opts := []*sql.TxOptions{}
+	if cfg.TxOptions != nil {
+		opts = append(opts, cfg.TxOptions)
+	}
+
+	if InTx(ctx) {
+		return Tx(ctx, f, opts...)
+	}
+
+	backoff := cfg.MinBackoff
+	var err error
+	for i := 0; i < cfg.Attempts; i++ {
+		if i > 0 {
+			wait := backoff/2 + rand.N(backoff/2+1)
+			select {
+			case <-ctx.Done():
+				return errors.Join(ctx.Err(), err)
+			case <-time.After(wait):
+			}
+			backoff = min(backoff*2, cfg.MaxBackoff)
+		}
+
+		err = Tx(ctx, f, opts...)
+		if err == nil || !cfg.Retryable(err) {
+			return err
+		}
+	}
+	return err
+}
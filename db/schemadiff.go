@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type DriftKind string
+
+const (
+	DriftMissingTable      DriftKind = "missing_table"
+	DriftMissingColumn     DriftKind = "missing_column"
+	DriftColumnChanged     DriftKind = "column_changed"
+	DriftExtraColumn       DriftKind = "extra_column"
+	DriftMissingIndex      DriftKind = "missing_index"
+	DriftMissingConstraint DriftKind = "missing_constraint"
+)
+
+// Drift 描述模型与数据库之间的一处差异，SQL 为修复该差异的语句，
+// 无法生成时（如 SQLite 修改列需要重建表）Error 记录原因。
+type Drift struct {
+	Kind     DriftKind `json:"kind"`
+	Table    string    `json:"table"`
+	Name     string    `json:"name,omitempty"`
+	Expected string    `json:"expected,omitempty"`
+	Actual   string    `json:"actual,omitempty"`
+	SQL      []string  `json:"sql,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (d Drift) String() string {
+	s := string(d.Kind) + " " + d.Table
+	if d.Name != "" {
+		s += "." + d.Name
+	}
+	if d.Expected != "" || d.Actual != "" {
+		s += fmt.Sprintf(" expected=%q actual=%q", d.Expected, d.Actual)
+	}
+	return s
+}
+
+type SchemaDiffConfig struct {
+	Drop bool
+}
+
+type SchemaDiffOption func(*SchemaDiffConfig)
+
+// WithSchemaDiffDrop 为数据库中多出的列生成 DROP COLUMN，默认只报告。
+func WithSchemaDiffDrop() SchemaDiffOption {
+	return func(cfg *SchemaDiffConfig) {
+		cfg.Drop = true
+	}
+}
+
+// sqlCapture 收集 DryRun 会话中执行的 DDL。
+type sqlCapture struct {
+	logger.Interface
+	sqls []string
+}
+
+var ddlPrefix = regexp.MustCompile(`(?i)^\s*(create|alter|drop|comment)\s`)
+
+func (c *sqlCapture) LogMode(logger.LogLevel) logger.Interface {
+	return c
+}
+
+func (c *sqlCapture) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if sql, _ := fc(); ddlPrefix.MatchString(sql) {
+		c.sqls = append(c.sqls, strings.Join(strings.Fields(sql), " "))
+	}
+}
+
+func (c *sqlCapture) take() []string {
+	sqls := c.sqls
+	c.sqls = nil
+	return sqls
+}
+
+// dryRun 执行 DryRun 迁移，需要读取现有表结构的操作（如 SQLite 重建表）在 DryRun 下会 panic，转换为错误。
+func dryRun(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("dry run: %v", r)
+		}
+	}()
+	return f()
+}
+
+func columnDesc(c gorm.ColumnType) string {
+	desc, ok := c.ColumnType()
+	if !ok || desc == "" {
+		desc = c.DatabaseTypeName()
+	}
+	if nullable, ok := c.Nullable(); ok && !nullable {
+		desc += " NOT NULL"
+	}
+	if dv, ok := c.DefaultValue(); ok {
+		desc += " DEFAULT " + dv
+	}
+	return desc
+}
+
+// SchemaDiff 比较 models 与当前数据库的结构，返回缺失的表、列、索引、约束，
+// 类型/可空/默认值不一致的列以及数据库中多出的列。
+// 判断规则与 AutoMigrate 相同，修复语句由 DryRun 执行迁移得到，即 AutoMigrate 会执行的 SQL；
+// 多出的列默认只报告，见 WithSchemaDiffDrop。models 按传入顺序处理，有外键依赖时被依赖的模型应放在前面。
+func SchemaDiff(ctx context.Context, models []any, ops ...SchemaDiffOption) ([]Drift, error) {
+	cfg := SchemaDiffConfig{}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	db, err := TryFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	capture := &sqlCapture{Interface: logger.Discard}
+	m := db.Migrator()
+	dm := db.Session(&gorm.Session{DryRun: true, Logger: capture}).Migrator()
+
+	drifts := []Drift{}
+	add := func(d Drift, err error) {
+		d.SQL = capture.take()
+		if err != nil {
+			d.Error = err.Error()
+		}
+		drifts = append(drifts, d)
+	}
+
+	for _, model := range models {
+		s, err := parseSchema(db, model)
+		if err != nil {
+			return nil, err
+		}
+		if !m.HasTable(model) {
+			add(Drift{Kind: DriftMissingTable, Table: s.Table}, dryRun(func() error { return dm.CreateTable(model) }))
+			continue
+		}
+
+		columns, err := m.ColumnTypes(model)
+		if err != nil {
+			return nil, err
+		}
+		live := map[string]gorm.ColumnType{}
+		for _, c := range columns {
+			live[c.Name()] = c
+		}
+		for _, name := range s.DBNames {
+			f := s.FieldsByDBName[name]
+			c, ok := live[name]
+			delete(live, name)
+			// 与 AutoMigrate 相同，跳过 -:migration 字段，已存在的同名列也不算多出的列
+			if f.IgnoreMigration {
+				continue
+			}
+			if !ok {
+				add(Drift{
+					Kind:     DriftMissingColumn,
+					Table:    s.Table,
+					Name:     name,
+					Expected: dm.FullDataTypeOf(f).SQL,
+				}, dryRun(func() error { return dm.AddColumn(model, name) }))
+				continue
+			}
+			err := dryRun(func() error { return dm.MigrateColumn(model, f, c) })
+			if len(capture.sqls) == 0 && err == nil {
+				continue
+			}
+			add(Drift{
+				Kind:     DriftColumnChanged,
+				Table:    s.Table,
+				Name:     name,
+				Expected: dm.FullDataTypeOf(f).SQL,
+				Actual:   columnDesc(c),
+			}, err)
+		}
+
+		extra := make([]string, 0, len(live))
+		for name := range live {
+			extra = append(extra, name)
+		}
+		slices.Sort(extra)
+		for _, name := range extra {
+			var err error
+			if cfg.Drop {
+				err = dryRun(func() error { return dm.DropColumn(model, name) })
+			}
+			add(Drift{Kind: DriftExtraColumn, Table: s.Table, Name: name, Actual: columnDesc(live[name])}, err)
+		}
+
+		constraints := []string{}
+		if !db.DisableForeignKeyConstraintWhenMigrating && !db.IgnoreRelationshipsWhenMigrating {
+			for _, rel := range s.Relationships.Relations {
+				if rel.Field.IgnoreMigration {
+					continue
+				}
+				if c := rel.ParseConstraint(); c != nil && c.Schema == s {
+					constraints = append(constraints, c.Name)
+				}
+			}
+		}
+		for name := range s.ParseCheckConstraints() {
+			constraints = append(constraints, name)
+		}
+		slices.Sort(constraints)
+		for _, name := range slices.Compact(constraints) {
+			if !m.HasConstraint(model, name) {
+				add(Drift{Kind: DriftMissingConstraint, Table: s.Table, Name: name}, dryRun(func() error { return dm.CreateConstraint(model, name) }))
+			}
+		}
+
+		for _, idx := range s.ParseIndexes() {
+			if !m.HasIndex(model, idx.Name) {
+				add(Drift{Kind: DriftMissingIndex, Table: s.Table, Name: idx.Name}, dryRun(func() error { return dm.CreateIndex(model, idx.Name) }))
+			}
+		}
+	}
+	return drifts, nil
+}
+
+// MigrationSQL 合并 drifts 中的 SQL，每行一条语句，与 ExecuteSQLFilesFromEmbed 按行执行的方式一致。
+func MigrationSQL(drifts []Drift) string {
+	b := strings.Builder{}
+	for _, d := range drifts {
+		for _, sql := range d.SQL {
+			b.WriteString(strings.TrimSuffix(sql, ";"))
+			b.WriteString(";\n")
+		}
+	}
+	return b.String()
+}
+
+var migrationName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// WriteMigration 将 drifts 的 SQL 写入 dir/<UTC 时间戳>_<name>.sql，供 ExecuteSQLFilesFromEmbed 嵌入执行，
+// 文件名按时间排序即为执行顺序。没有可执行的 SQL 时不写文件，返回空路径。
+func WriteMigration(dir, name string, drifts []Drift) (string, error) {
+	sql := MigrationSQL(drifts)
+	if sql == "" {
+		return "", nil
+	}
+	name = strings.Trim(migrationName.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		name = "schema"
+	}
+	path := filepath.Join(dir, time.Now().UTC().Format("20060102150405")+"_"+name+".sql")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	// O_EXCL 避免同一秒内生成的迁移互相覆盖
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(sql); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
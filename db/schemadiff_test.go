@@ -0,0 +1,107 @@
+package db
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+type schemaDiffTestModel struct {
+	Model
+	Name  string `gorm:"index"`
+	Email string
+	Score int `gorm:"-:migration"`
+}
+
+type schemaDiffNewModel struct {
+	Model
+	Code string
+}
+
+// TestSchemaDiff 确认能发现缺失的表、列、索引以及多出的列，跳过 -:migration 字段，生成的迁移执行后不再有可修复的差异。
+func TestSchemaDiff(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Exec("CREATE TABLE schema_diff_test_models (id integer PRIMARY KEY, created_at datetime, updated_at datetime, deleted_at datetime, name text, legacy text)").Error; err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	ctx := testCtx(db)
+	models := []any{&schemaDiffTestModel{}, &schemaDiffNewModel{}}
+
+	drifts, err := SchemaDiff(ctx, models)
+	if err != nil {
+		t.Fatalf("比较失败: %v", err)
+	}
+	kinds := map[string]Drift{}
+	for _, d := range drifts {
+		kinds[string(d.Kind)+":"+d.Table+"."+d.Name] = d
+	}
+	for _, k := range []string{
+		"missing_column:schema_diff_test_models.email",
+		"extra_column:schema_diff_test_models.legacy",
+		"missing_index:schema_diff_test_models.idx_schema_diff_test_models_name",
+		"missing_index:schema_diff_test_models.idx_schema_diff_test_models_deleted_at",
+		"missing_table:schema_diff_new_models.",
+	} {
+		if _, ok := kinds[k]; !ok {
+			t.Fatalf("缺少差异 %s: %v", k, drifts)
+		}
+	}
+	if d := kinds["extra_column:schema_diff_test_models.legacy"]; len(d.SQL) != 0 {
+		t.Fatalf("默认不应生成删除列的 SQL: %v", d.SQL)
+	}
+	for _, d := range drifts {
+		if d.Name == "score" {
+			t.Fatalf("-:migration 字段不应报告差异: %v", d)
+		}
+	}
+
+	dir := t.TempDir()
+	path, err := WriteMigration(dir, "Sync Schema!", drifts)
+	if err != nil || !strings.HasSuffix(path, "_sync_schema.sql") {
+		t.Fatalf("写入迁移失败: %s %v", path, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取迁移失败: %v", err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := db.Exec(line).Error; err != nil {
+			t.Fatalf("执行迁移失败 %s: %v", line, err)
+		}
+	}
+
+	drifts, err = SchemaDiff(ctx, models)
+	if err != nil {
+		t.Fatalf("比较失败: %v", err)
+	}
+	if len(drifts) != 1 || drifts[0].Kind != DriftExtraColumn {
+		t.Fatalf("迁移后应只剩多出的列: %v", drifts)
+	}
+	if path, err := WriteMigration(dir, "noop", drifts); err != nil || path != "" {
+		t.Fatalf("没有 SQL 时不应写文件: %s %v", path, err)
+	}
+}
+
+type schemaDiffChangedModel struct {
+	Model
+	Age int `gorm:"not null;default:3"`
+}
+
+// TestSchemaDiffColumnChanged 确认能发现类型不一致的列，SQLite 需要重建表无法生成 SQL 时记录原因而不是 panic。
+func TestSchemaDiffColumnChanged(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Exec("CREATE TABLE schema_diff_changed_models (id integer PRIMARY KEY, created_at datetime, updated_at datetime, deleted_at datetime, age text)").Error; err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+	drifts, err := SchemaDiff(testCtx(db), []any{&schemaDiffChangedModel{}})
+	if err != nil {
+		t.Fatalf("比较失败: %v", err)
+	}
+	d := drifts[0]
+	if d.Kind != DriftColumnChanged || d.Name != "age" || d.Actual != "text" || d.Error == "" || len(d.SQL) != 0 {
+		t.Fatalf("列差异不正确: %+v", d)
+	}
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNoTenant       = errors.New("tenant required")
+	ErrTenantMismatch = errors.New("tenant mismatch")
+)
+
+type tenantKey struct{}
+
+var _tenantKey = tenantKey{}
+
+type tenantSkipKey struct{}
+
+var _tenantSkipKey = tenantSkipKey{}
+
+// TenantCtx 设置当前租户，之后通过 For(ctx) 执行的语句会自动按租户隔离。
+func TenantCtx(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, _tenantKey, tenant)
+}
+
+// TenantFrom 获取当前租户。
+func TenantFrom(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	t, ok := ctx.Value(_tenantKey).(string)
+	return t, ok && t != ""
+}
+
+// SkipTenantCtx 跳过租户隔离，用于后台任务、跨租户统计等场景。
+func SkipTenantCtx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, _tenantSkipKey, true)
+}
+
+type TenantConfig struct {
+	Column   string
+	Required bool
+	Schema   func(tenant string) string
+}
+
+type TenantOption func(*TenantConfig)
+
+// WithTenantColumn 设置租户列名，默认 tenant_id，没有该列的模型不做隔离。
+func WithTenantColumn(column string) TenantOption {
+	return func(cfg *TenantConfig) {
+		cfg.Column = column
+	}
+}
+
+// WithTenantRequired 访问带租户列的模型时 ctx 中必须有租户（或 SkipTenantCtx），否则返回 ErrNoTenant。
+func WithTenantRequired() TenantOption {
+	return func(cfg *TenantConfig) {
+		cfg.Required = true
+	}
+}
+
+// WithTenantSchema 启用每租户一个 schema 的模式（Postgres），schema 返回租户对应的 schema 名。
+// 此模式下所有模型的表名都会加上 schema 前缀，不再按租户列过滤。
+// 新租户需要先调用 MigrateTenantSchema 建表。
+func WithTenantSchema(schema func(tenant string) string) TenantOption {
+	return func(cfg *TenantConfig) {
+		cfg.Schema = schema
+	}
+}
+
+// NewTenantOp 注册租户隔离插件，通过 gorm 回调：
+// 查询、更新、删除时追加 租户列 = 当前租户 条件，创建时填充租户列（已填写且不一致时返回 ErrTenantMismatch）。
+// Raw/Exec 执行的原生 SQL 不做处理。
+// 回调从语句的 Context 获取租户，For(ctx) 返回的 db 已带有 ctx，Loader/Loaders 也会按批次的 ctx 隔离，
+// 注意 Loader 合并的是同一批次内的请求，需要按请求（租户）创建。
+func NewTenantOp(ops ...TenantOption) Option {
+	p := &tenantPlugin{cfg: TenantConfig{Column: "tenant_id"}}
+	for _, op := range ops {
+		op(&p.cfg)
+	}
+	return func(cfg *gorm.Config) {
+		if cfg.Plugins == nil {
+			cfg.Plugins = map[string]gorm.Plugin{}
+		}
+		cfg.Plugins[p.Name()] = p
+	}
+}
+
+type tenantPlugin struct {
+	cfg TenantConfig
+}
+
+func (p *tenantPlugin) Name() string {
+	return "nzlov:tenant"
+}
+
+func (p *tenantPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("nzlov:tenant_create", p.create),
+		cb.Query().Before("gorm:query").Register("nzlov:tenant_query", p.scope),
+		cb.Update().Before("gorm:update").Register("nzlov:tenant_update", p.scope),
+		cb.Delete().Before("gorm:delete").Register("nzlov:tenant_delete", p.scope),
+		cb.Row().Before("gorm:row").Register("nzlov:tenant_row", p.scope),
+	)
+}
+
+// tenant 返回当前语句需要使用的租户，不需要隔离时返回 false。
+func (p *tenantPlugin) tenant(db *gorm.DB) (string, bool) {
+	ctx := db.Statement.Context
+	if skip, _ := ctx.Value(_tenantSkipKey).(bool); skip {
+		return "", false
+	}
+	t, ok := TenantFrom(ctx)
+	if p.cfg.Schema != nil {
+		if !ok && p.cfg.Required {
+			db.AddError(ErrNoTenant)
+		}
+		return t, ok
+	}
+	if db.Statement.Schema == nil || db.Statement.Schema.LookUpField(p.cfg.Column) == nil {
+		return "", false
+	}
+	if !ok && p.cfg.Required {
+		db.AddError(fmt.Errorf("%w: %s", ErrNoTenant, db.Statement.Table))
+	}
+	return t, ok
+}
+
+// withSchema 为表名加上租户 schema 前缀。
+func (p *tenantPlugin) withSchema(db *gorm.DB, tenant string) {
+	stmt := db.Statement
+	prefix := p.cfg.Schema(tenant) + "."
+	if stmt.TableExpr != nil || stmt.Table == "" || strings.HasPrefix(stmt.Table, prefix) {
+		return
+	}
+	stmt.Table = prefix + stmt.Table
+}
+
+func (p *tenantPlugin) scope(db *gorm.DB) {
+	t, ok := p.tenant(db)
+	if !ok {
+		return
+	}
+	if p.cfg.Schema != nil {
+		p.withSchema(db, t)
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: p.cfg.Column}, Value: t},
+	}})
+}
+
+func (p *tenantPlugin) create(db *gorm.DB) {
+	t, ok := p.tenant(db)
+	if !ok {
+		return
+	}
+	if p.cfg.Schema != nil {
+		p.withSchema(db, t)
+		return
+	}
+
+	f := db.Statement.Schema.LookUpField(p.cfg.Column)
+	ctx := db.Statement.Context
+	set := func(rv reflect.Value) {
+		v, zero := f.ValueOf(ctx, rv)
+		if !zero {
+			if fmt.Sprint(v) != t {
+				db.AddError(fmt.Errorf("%w: %v != %s", ErrTenantMismatch, v, t))
+			}
+			return
+		}
+		if err := f.Set(ctx, rv, t); err != nil {
+			db.AddError(err)
+		}
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if e := reflect.Indirect(rv.Index(i)); e.Kind() == reflect.Struct {
+				set(e)
+			}
+		}
+	case reflect.Struct:
+		set(rv)
+	case reflect.Map:
+		// Create(map[string]any) 时直接写入租户列
+		if m, ok := db.Statement.Dest.(map[string]any); ok {
+			m[p.cfg.Column] = t
+		}
+	}
+}
+
+// MigrateTenantSchema 在每租户一个 schema 的模式下为租户创建 schema 并迁移 models。
+func MigrateTenantSchema(ctx context.Context, tenant string, schemaName func(string) string, models ...any) error {
+	db, err := TryFor(SkipTenantCtx(ctx))
+	if err != nil {
+		return err
+	}
+	name := schemaName(tenant)
+	if err := db.Exec("CREATE SCHEMA IF NOT EXISTS ?", clause.Table{Name: name}).Error; err != nil {
+		return err
+	}
+	for _, m := range models {
+		s, err := parseSchema(db, m)
+		if err != nil {
+			return err
+		}
+		if err := db.Table(name + "." + s.Table).AutoMigrate(m); err != nil {
+			return fmt.Errorf("migrate %s: %w", s.Table, err)
+		}
+	}
+	return nil
+}
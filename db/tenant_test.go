@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type tenantTestModel struct {
+	Model
+	TenantID string `gorm:"index"`
+	Name     string
+}
+
+// TestTenantColumn 确认按租户列自动过滤查询、更新、删除，并在创建时填充租户。
+func TestTenantColumn(t *testing.T) {
+	gdb := openTestDB(t, NewTenantOp(WithTenantRequired()))
+	if err := gdb.AutoMigrate(&tenantTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	ctx := testCtx(gdb)
+	t1, t2 := TenantCtx(ctx, "t1"), TenantCtx(ctx, "t2")
+
+	if err := For(t1).Create(&[]tenantTestModel{{Name: "a"}, {Name: "b"}}).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	other := tenantTestModel{Name: "c"}
+	if err := For(t2).Create(&other).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if other.TenantID != "t2" {
+		t.Fatalf("创建时未填充租户: %q", other.TenantID)
+	}
+	if err := For(t1).Create(&tenantTestModel{TenantID: "t2"}).Error; !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("租户不一致时应返回 ErrTenantMismatch: %v", err)
+	}
+
+	objs := []tenantTestModel{}
+	if err := For(t1).Find(&objs).Error; err != nil || len(objs) != 2 {
+		t.Fatalf("查询应只返回本租户数据: %d %v", len(objs), err)
+	}
+	n := int64(0)
+	if err := For(t1).Model(&tenantTestModel{}).Where("id = ?", other.ID).Count(&n).Error; err != nil || n != 0 {
+		t.Fatalf("不应统计到其他租户数据: %d %v", n, err)
+	}
+
+	if rs := For(t1).Model(&tenantTestModel{}).Where("id = ?", other.ID).Update("name", "x"); rs.Error != nil || rs.RowsAffected != 0 {
+		t.Fatalf("不应更新其他租户数据: %d %v", rs.RowsAffected, rs.Error)
+	}
+	if rs := For(t1).Delete(&tenantTestModel{}, other.ID); rs.Error != nil || rs.RowsAffected != 0 {
+		t.Fatalf("不应删除其他租户数据: %d %v", rs.RowsAffected, rs.Error)
+	}
+
+	loader := Loader("name", func(v tenantTestModel) string { return v.Name })
+	if _, err := loader.Load(t1, "a"); err != nil {
+		t.Fatalf("Loader 读取本租户数据失败: %v", err)
+	}
+	if _, err := loader.Load(t1, "c"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Loader 不应读取到其他租户数据: %v", err)
+	}
+
+	if err := For(ctx).Find(&objs).Error; !errors.Is(err, ErrNoTenant) {
+		t.Fatalf("缺少租户时应返回 ErrNoTenant: %v", err)
+	}
+	if err := For(SkipTenantCtx(ctx)).Find(&objs).Error; err != nil || len(objs) != 3 {
+		t.Fatalf("跳过租户隔离时应返回全部数据: %d %v", len(objs), err)
+	}
+
+	// 没有租户列的模型不受影响
+	if err := For(ctx).Find(&[]dbAllTestModel{}).Error; err != nil {
+		t.Fatalf("无租户列的模型不应要求租户: %v", err)
+	}
+}
+
+// TestTenantSchema 确认每租户 schema 模式下表名带上 schema 前缀。
+func TestTenantSchema(t *testing.T) {
+	cfg := &gorm.Config{DryRun: true, DisableAutomaticPing: true}
+	NewTenantOp(WithTenantSchema(func(t string) string { return "tenant_" + t }))(cfg)
+	pg, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), cfg)
+	if err != nil {
+		t.Fatalf("打开 postgres 失败: %v", err)
+	}
+	ctx := TenantCtx(Ctx(context.Background(), pg), "a")
+
+	stmt := For(ctx).Where("name = ?", "x").Find(&[]tenantTestModel{}).Statement
+	want := `SELECT * FROM "tenant_a"."tenant_test_models" WHERE name = $1 AND "tenant_a"."tenant_test_models"."deleted_at" IS NULL`
+	if sql := stmt.SQL.String(); sql != want {
+		t.Fatalf("生成的 SQL 不正确:\n%s\n%s", sql, want)
+	}
+}
+
+// TestTenantBulkInsert 确认 BulkInsert 填充租户列，并且有租户或租户插件时 Postgres 上不使用绕过回调的 COPY。
+func TestTenantBulkInsert(t *testing.T) {
+	gdb := openTestDB(t, NewTenantOp())
+	if err := gdb.AutoMigrate(&tenantTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	ctx := TenantCtx(testCtx(gdb), "t1")
+	if _, err := BulkInsert(ctx, []tenantTestModel{{Name: "a"}, {Name: "b"}}); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	var n int64
+	gdb.Model(&tenantTestModel{}).Where("tenant_id = ?", "t1").Count(&n)
+	if n != 2 {
+		t.Fatalf("批量写入未填充租户: %d", n)
+	}
+
+	pg, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("打开 postgres 失败: %v", err)
+	}
+	if needCallbacks(context.Background(), pg) {
+		t.Fatal("没有租户和插件时应允许 COPY")
+	}
+	if !needCallbacks(TenantCtx(context.Background(), "t1"), pg) {
+		t.Fatal("ctx 中有租户时不应使用 COPY")
+	}
+	pgt, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, Plugins: map[string]gorm.Plugin{}})
+	if err != nil {
+		t.Fatalf("打开 postgres 失败: %v", err)
+	}
+	pgt.Use(&tenantPlugin{cfg: TenantConfig{Column: "tenant_id"}})
+	if !needCallbacks(context.Background(), pgt) {
+		t.Fatal("注册租户插件时不应使用 COPY")
+	}
+}
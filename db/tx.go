@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+var _txKey = txKey{}
+
+// txHooks 保存一层事务内注册的回调。
+// 嵌套事务成功时回调并入外层，失败时立即执行本层的 OnRollback 回调并丢弃 OnCommit 回调。
+type txHooks struct {
+	mu         sync.Mutex
+	depth      int
+	root       *gorm.DB // 开启最外层事务前的 db
+	onCommit   []func(context.Context)
+	onRollback []func(context.Context)
+}
+
+func (h *txHooks) merge(c *txHooks) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onCommit = append(h.onCommit, c.onCommit...)
+	h.onRollback = append(h.onRollback, c.onRollback...)
+}
+
+func (h *txHooks) commit(ctx context.Context) {
+	h.mu.Lock()
+	fs := h.onCommit
+	h.onCommit, h.onRollback = nil, nil
+	h.mu.Unlock()
+	for _, f := range fs {
+		f(ctx)
+	}
+}
+
+func (h *txHooks) rollback(ctx context.Context) {
+	h.mu.Lock()
+	fs := h.onRollback
+	h.onCommit, h.onRollback = nil, nil
+	h.mu.Unlock()
+	for _, f := range fs {
+		f(ctx)
+	}
+}
+
+func getTxHooks(ctx context.Context) *txHooks {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(_txKey).(*txHooks)
+	return h
+}
+
+// Tx 在事务中执行 f，f 中应通过 For(ctx) 获取事务内的 db。
+// 如果 ctx 已处于事务中，则使用 SAVEPOINT 开启嵌套事务：f 返回错误只回滚到该保存点，
+// 外层事务仍可继续提交。
+// OnCommit 注册的回调在最外层事务提交后执行，OnRollback 注册的回调在所属层回滚后执行，
+// 回调拿到的是调用 Tx 时传入的 ctx。
+func Tx(ctx context.Context, f func(context.Context) error, opts ...*sql.TxOptions) (err error) {
+	db, err := TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, end := startTxSpan(ctx, db)
+	defer func() { end(err) }()
+
+	parent := getTxHooks(ctx)
+	hooks := &txHooks{depth: 1, root: db}
+	if parent != nil {
+		hooks.depth = parent.depth + 1
+		hooks.root = parent.root
+	}
+
+	committed := false
+	defer func() {
+		// f panic 时 gorm 会回滚后继续抛出，这里同样需要执行回滚回调
+		if !committed {
+			hooks.rollback(ctx)
+		}
+	}()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return f(context.WithValue(Ctx(ctx, tx), _txKey, hooks))
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	committed = true
+	if parent != nil {
+		parent.merge(hooks)
+	} else {
+		hooks.commit(ctx)
+	}
+	return nil
+}
+
+// InTx 判断 ctx 是否处于 Tx 开启的事务中。
+func InTx(ctx context.Context) bool {
+	return getTxHooks(ctx) != nil
+}
+
+// WithoutTx 返回脱离当前事务的 ctx，其中的 db 为开启最外层事务前的 db，
+// 用于写入不应随业务事务回滚的数据（如发号段、日志）。不在事务中时原样返回。
+func WithoutTx(ctx context.Context) context.Context {
+	h := getTxHooks(ctx)
+	if h == nil {
+		return ctx
+	}
+	return context.WithValue(Ctx(ctx, h.root), _txKey, (*txHooks)(nil))
+}
+
+// TxDepth 返回当前事务嵌套层数，不在事务中时为 0。
+func TxDepth(ctx context.Context) int {
+	h := getTxHooks(ctx)
+	if h == nil {
+		return 0
+	}
+	return h.depth
+}
+
+// OnCommit 注册事务提交后执行的回调，常用于发布事件、清理缓存等不能回滚的操作。
+// 不在事务中时立即执行。
+func OnCommit(ctx context.Context, f func(context.Context)) {
+	h := getTxHooks(ctx)
+	if h == nil {
+		f(ctx)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onCommit = append(h.onCommit, f)
+}
+
+// OnRollback 注册当前层事务回滚后执行的回调，不在事务中时忽略。
+func OnRollback(ctx context.Context, f func(context.Context)) {
+	h := getTxHooks(ctx)
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onRollback = append(h.onRollback, f)
+}
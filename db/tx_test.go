@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestTxOnCommit 确认提交回调在事务提交后才执行，且嵌套事务的回调并入最外层。
+func TestTxOnCommit(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testCtx(db)
+
+	var calls []string
+	err := Tx(ctx, func(ctx context.Context) error {
+		if !InTx(ctx) || TxDepth(ctx) != 1 {
+			t.Fatalf("事务层数不正确: %d", TxDepth(ctx))
+		}
+		OnCommit(ctx, func(context.Context) { calls = append(calls, "outer") })
+		if err := Tx(ctx, func(ctx context.Context) error {
+			if TxDepth(ctx) != 2 {
+				t.Fatalf("嵌套事务层数不正确: %d", TxDepth(ctx))
+			}
+			OnCommit(ctx, func(context.Context) { calls = append(calls, "inner") })
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(calls) != 0 {
+			t.Fatalf("提交前不应执行回调: %v", calls)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("事务执行失败: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "outer" || calls[1] != "inner" {
+		t.Fatalf("提交回调不正确: %v", calls)
+	}
+	if InTx(ctx) {
+		t.Fatalf("事务外 InTx 应为 false")
+	}
+}
+
+// TestTxNestedRollback 验证嵌套事务失败只回滚到保存点，外层写入仍然提交。
+func TestTxNestedRollback(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testCtx(db)
+
+	wantErr := errors.New("inner failed")
+	var committed, rolledBack bool
+	err := Tx(ctx, func(ctx context.Context) error {
+		if err := For(ctx).Create(&dbAllTestModel{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		err := Tx(ctx, func(ctx context.Context) error {
+			OnCommit(ctx, func(context.Context) { committed = true })
+			OnRollback(ctx, func(context.Context) { rolledBack = true })
+			if err := For(ctx).Create(&dbAllTestModel{Name: "inner"}).Error; err != nil {
+				return err
+			}
+			return wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Fatalf("嵌套事务错误不正确: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("事务执行失败: %v", err)
+	}
+	if committed || !rolledBack {
+		t.Fatalf("回调执行不正确: committed=%v rolledBack=%v", committed, rolledBack)
+	}
+
+	var names []string
+	if err := db.Model(&dbAllTestModel{}).Pluck("name", &names).Error; err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(names) != 1 || names[0] != "outer" {
+		t.Fatalf("保存点回滚结果不正确: %v", names)
+	}
+}
+
+// TestTxRetry 验证可重试错误会重新执行整个事务，且失败尝试的提交回调不会被执行。
+func TestTxRetry(t *testing.T) {
+	db := openTestDB(t)
+	ctx := testCtx(db)
+
+	retryErr := errors.New("conflict")
+	attempts := 0
+	commits := 0
+	err := TxRetry(ctx, func(ctx context.Context) error {
+		attempts++
+		OnCommit(ctx, func(context.Context) { commits++ })
+		if attempts < 3 {
+			return retryErr
+		}
+		return nil
+	}, WithTxRetryAttempts(5), WithTxRetryBackoff(time.Millisecond, time.Millisecond),
+		WithTxRetryClassifier(func(err error) bool { return errors.Is(err, retryErr) }))
+	if err != nil {
+		t.Fatalf("重试事务失败: %v", err)
+	}
+	if attempts != 3 || commits != 1 {
+		t.Fatalf("重试次数或提交回调不正确: attempts=%d commits=%d", attempts, commits)
+	}
+
+	attempts = 0
+	err = TxRetry(ctx, func(ctx context.Context) error {
+		attempts++
+		return retryErr
+	}, WithTxRetryAttempts(2), WithTxRetryBackoff(time.Millisecond, time.Millisecond),
+		WithTxRetryClassifier(func(err error) bool { return errors.Is(err, retryErr) }))
+	if !errors.Is(err, retryErr) || attempts != 2 {
+		t.Fatalf("超过最大次数后应返回最后的错误: err=%v attempts=%d", err, attempts)
+	}
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type typesTestModel struct {
+	Model
+	Secret  Encrypted[map[string]string]
+	Amount  Decimal `gorm:"precision:38;scale:18"`
+	Price   Null[Decimal]
+	Count   Null[int64]
+	Expires Null[time.Time]
+}
+
+// TestColumnTypes 确认加密列、精确小数与可空列在 SQLite 上原样往返，并支持密钥轮换。
+func TestColumnTypes(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&typesTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	old := []byte("0123456789abcdef")
+	if err := SetEncryptionKeys("k1", map[string][]byte{"k1": old}); err != nil {
+		t.Fatalf("设置密钥失败: %v", err)
+	}
+	defer _encryptionKeys.Store(nil)
+
+	amount, _ := ParseDecimal("12345678901234567890.123456789012345678")
+	obj := typesTestModel{
+		Secret: NewEncrypted(map[string]string{"card": "4111"}),
+		Amount: amount,
+		Price:  NewNull(DecimalFromMinor(1999, 2)),
+		Count:  NewNull(int64(0)),
+	}
+	if err := db.Create(&obj).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	raw := ""
+	db.Model(&typesTestModel{}).Select("secret").Where("id = ?", obj.ID).Scan(&raw)
+	if len(raw) < 3 || raw[:3] != "k1:" || json.Valid([]byte(raw)) {
+		t.Fatalf("加密列应保存密文: %s", raw)
+	}
+
+	// 轮换密钥后旧数据仍可读取，并标记为需要重新加密
+	if err := SetEncryptionKeys("k2", map[string][]byte{"k1": old, "k2": []byte("fedcba9876543210")}); err != nil {
+		t.Fatalf("设置密钥失败: %v", err)
+	}
+	got := typesTestModel{}
+	if err := db.First(&got, obj.ID).Error; err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if got.Secret.V["card"] != "4111" || !got.Secret.Stale() {
+		t.Fatalf("加密列读取不正确: %+v", got.Secret)
+	}
+	if !got.Amount.Equal(amount.Decimal) || got.Amount.String() != amount.String() {
+		t.Fatalf("小数精度丢失: %s != %s", got.Amount, amount)
+	}
+	if !got.Price.Valid || got.Price.V.String() != "19.99" {
+		t.Fatalf("可空小数不正确: %+v", got.Price)
+	}
+	if !got.Count.Valid || got.Count.V != 0 || got.Expires.Valid {
+		t.Fatalf("可空列不正确: %+v %+v", got.Count, got.Expires)
+	}
+
+	if err := db.Save(&got).Error; err != nil {
+		t.Fatalf("重新保存失败: %v", err)
+	}
+	db.Model(&typesTestModel{}).Select("secret").Where("id = ?", obj.ID).Scan(&raw)
+	if raw[:3] != "k2:" {
+		t.Fatalf("重新保存后应使用新密钥: %s", raw)
+	}
+
+	data, _ := json.Marshal(got)
+	m := map[string]any{}
+	json.Unmarshal(data, &m)
+	if m["Expires"] != nil || m["Amount"] != amount.String() || m["Secret"].(map[string]any)["card"] != "4111" {
+		t.Fatalf("JSON 序列化不正确: %s", data)
+	}
+
+	_encryptionKeys.Store(nil)
+	if err := db.First(&typesTestModel{}, obj.ID).Error; !errors.Is(err, ErrNoEncryptionKey) {
+		t.Fatalf("缺少密钥时应返回 ErrNoEncryptionKey: %v", err)
+	}
+}
+
+// TestEncryptedLongValue 确认密文超过 256 字节时可以完整往返，MySQL 上使用 longtext 而不是默认的 varchar(256)。
+func TestEncryptedLongValue(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.AutoMigrate(&typesTestModel{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	if err := SetEncryptionKeys("k1", map[string][]byte{"k1": []byte("0123456789abcdef")}); err != nil {
+		t.Fatalf("设置密钥失败: %v", err)
+	}
+	defer _encryptionKeys.Store(nil)
+
+	long := strings.Repeat("x", 1024)
+	obj := typesTestModel{Secret: NewEncrypted(map[string]string{"note": long})}
+	if err := db.Create(&obj).Error; err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	got := typesTestModel{}
+	if err := db.First(&got, obj.ID).Error; err != nil || got.Secret.V["note"] != long {
+		t.Fatalf("长密文读取不正确: %v", err)
+	}
+
+	my, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(localhost)/test", SkipInitializeWithVersion: true, DefaultStringSize: 256}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("打开 mysql 失败: %v", err)
+	}
+	s, err := parseSchema(my, &typesTestModel{})
+	if err != nil {
+		t.Fatalf("解析模型失败: %v", err)
+	}
+	if typ := my.Migrator().FullDataTypeOf(s.LookUpField("Secret")).SQL; typ != "longtext" {
+		t.Fatalf("MySQL 列类型不正确: %s", typ)
+	}
+}
@@ -0,0 +1,205 @@
+package gormlog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzlov/utils/otel"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+)
+
+type ExplainConfig struct {
+	Analyze     bool
+	Interval    time.Duration
+	Timeout     time.Duration
+	Concurrency int
+}
+
+type ExplainOption func(*ExplainConfig)
+
+// WithExplainAnalyze 使用 EXPLAIN ANALYZE（Postgres、MySQL 8.0.18+），会真正执行一次语句，SQLite 不支持时忽略。
+func WithExplainAnalyze() ExplainOption {
+	return func(cfg *ExplainConfig) {
+		cfg.Analyze = true
+	}
+}
+
+// WithExplainInterval 同一指纹的语句两次 EXPLAIN 的最小间隔，默认 1 分钟。
+func WithExplainInterval(interval time.Duration) ExplainOption {
+	return func(cfg *ExplainConfig) {
+		cfg.Interval = interval
+	}
+}
+
+// WithExplainTimeout 单次 EXPLAIN 的超时时间，默认 5 秒。
+func WithExplainTimeout(timeout time.Duration) ExplainOption {
+	return func(cfg *ExplainConfig) {
+		cfg.Timeout = timeout
+	}
+}
+
+// WithExplainConcurrency 同时执行的 EXPLAIN 数量，超出时直接丢弃，默认 1。
+func WithExplainConcurrency(n int) ExplainOption {
+	return func(cfg *ExplainConfig) {
+		cfg.Concurrency = n
+	}
+}
+
+// Explainer 为慢 SELECT 异步执行 EXPLAIN，结果以 "slow sql plan" 日志（db.plan 属性）输出。
+// gorm.Open 之后、使用 db 之前设置到 GormLogger.Explainer：
+//
+//	lg := gormlog.Default()
+//	db, _ := gorm.Open(dialector, &gorm.Config{Logger: lg})
+//	lg.Explainer = gormlog.NewExplainer(db)
+type Explainer struct {
+	cfg  ExplainConfig
+	db   *gorm.DB
+	sem  chan struct{}
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func NewExplainer(db *gorm.DB, ops ...ExplainOption) *Explainer {
+	cfg := ExplainConfig{
+		Interval:    time.Minute,
+		Timeout:     5 * time.Second,
+		Concurrency: 1,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	return &Explainer{
+		cfg:  cfg,
+		db:   db.Session(&gorm.Session{NewDB: true, Logger: glogger.Discard}),
+		sem:  make(chan struct{}, max(cfg.Concurrency, 1)),
+		last: map[string]time.Time{},
+	}
+}
+
+// reserve 按指纹限流，同一指纹在 Interval 内只执行一次。检查与记录在同一把锁内完成，
+// 并发的同指纹语句只有一条能通过；返回的 release 用于未取得并发名额时撤销本次记录。
+func (e *Explainer) reserve(fingerprint string) (func(), bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	prev, had := e.last[fingerprint]
+	if had && now.Sub(prev) < e.cfg.Interval {
+		return nil, false
+	}
+	// 清理过期指纹，避免 map 无限增长
+	if len(e.last) > 1024 {
+		for k, t := range e.last {
+			if now.Sub(t) >= e.cfg.Interval {
+				delete(e.last, k)
+			}
+		}
+	}
+	e.last[fingerprint] = now
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if !e.last[fingerprint].Equal(now) {
+			return
+		}
+		if had {
+			e.last[fingerprint] = prev
+		} else {
+			delete(e.last, fingerprint)
+		}
+	}, true
+}
+
+func (e *Explainer) prefix() string {
+	switch e.db.Dialector.Name() {
+	case "sqlite":
+		return "EXPLAIN QUERY PLAN "
+	case "postgres":
+		if e.cfg.Analyze {
+			return "EXPLAIN (ANALYZE, BUFFERS) "
+		}
+	case "mysql":
+		if e.cfg.Analyze {
+			return "EXPLAIN ANALYZE "
+		}
+	}
+	return "EXPLAIN "
+}
+
+// Explain 异步分析 sql，只处理 SELECT，被限流或并发已满时直接返回。
+func (e *Explainer) Explain(ctx context.Context, sql string) {
+	if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(sql)), "select") {
+		return
+	}
+	fingerprint := Fingerprint(sql)
+	release, ok := e.reserve(fingerprint)
+	if !ok {
+		return
+	}
+	select {
+	case e.sem <- struct{}{}:
+	default:
+		// 被丢弃的语句不占用限流间隔
+		release()
+		return
+	}
+
+	go func() {
+		defer func() { <-e.sem }()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.cfg.Timeout)
+		defer cancel()
+
+		attrs := []any{
+			slog.String("db.statement", sql),
+			slog.String("db.fingerprint", fingerprint),
+		}
+		plan, err := e.plan(ctx, sql)
+		if err != nil {
+			otel.Warn(ctx, "slow sql explain failed", append(attrs, slog.String("db.error", err.Error()))...)
+			return
+		}
+		otel.Warn(ctx, "slow sql plan", append(attrs, slog.String("db.plan", plan))...)
+	}()
+}
+
+// plan 执行 EXPLAIN 并把结果整理为文本，单列结果（Postgres）逐行拼接，多列结果输出为 列=值。
+func (e *Explainer) plan(ctx context.Context, query string) (string, error) {
+	rows, err := e.db.WithContext(ctx).Raw(e.prefix() + query).Rows()
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		if len(columns) == 1 {
+			lines = append(lines, values[0].String)
+			continue
+		}
+		kvs := make([]string, 0, len(columns))
+		for i, c := range columns {
+			if values[i].Valid {
+				kvs = append(kvs, fmt.Sprintf("%s=%s", c, values[i].String))
+			}
+		}
+		lines = append(lines, strings.Join(kvs, " "))
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
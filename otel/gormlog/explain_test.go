@@ -0,0 +1,143 @@
+package gormlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nzlov/utils/otel"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+)
+
+// TestExplainer 确认慢 SELECT 会异步输出执行计划，同一指纹在间隔内只分析一次，非 SELECT 不分析。
+func TestExplainer(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:explain?mode=memory&cache=shared"), &gorm.Config{Logger: glogger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := db.Exec("CREATE TABLE IF NOT EXISTS items (id INTEGER PRIMARY KEY, name TEXT)").Error; err != nil {
+		t.Fatalf("建表失败: %v", err)
+	}
+
+	mu := &sync.Mutex{}
+	records := []record{}
+	ctx := otel.Ctx(context.Background(), testLogger{mu: mu, records: &records})
+	l := &GormLogger{LogLevel: glogger.Warn, SlowThreshold: time.Millisecond, Explainer: NewExplainer(db)}
+	begin := time.Now().Add(-10 * time.Millisecond)
+
+	l.Trace(ctx, begin, func() (string, int64) { return "SELECT * FROM items WHERE name = 'a'", 0 }, nil)
+	l.Trace(ctx, begin, func() (string, int64) { return "DELETE FROM items WHERE name = 'a'", 0 }, nil)
+
+	plans := func() []record {
+		mu.Lock()
+		defer mu.Unlock()
+		rs := []record{}
+		for _, r := range records {
+			if strings.HasPrefix(r.msg, "slow sql ") {
+				rs = append(rs, r)
+			}
+		}
+		return rs
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(plans()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	rs := plans()
+	if len(rs) != 1 || rs[0].msg != "slow sql plan" || !strings.Contains(rs[0].attrs["db.plan"].String(), "SCAN") {
+		t.Fatalf("执行计划日志不正确: %+v", rs)
+	}
+
+	l.Trace(ctx, begin, func() (string, int64) { return "SELECT * FROM items WHERE name = 'b'", 0 }, nil)
+	time.Sleep(100 * time.Millisecond)
+	if len(plans()) != 1 {
+		t.Fatalf("同一指纹在间隔内不应重复分析: %+v", plans())
+	}
+}
+
+// TestExplainerBusy 确认并发已满被丢弃的语句不记录指纹，名额空出后同一指纹仍能分析。
+func TestExplainerBusy(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:explain_busy?mode=memory&cache=shared"), &gorm.Config{Logger: glogger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	records := []record{}
+	ctx := otel.Ctx(context.Background(), testLogger{mu: &sync.Mutex{}, records: &records})
+	e := NewExplainer(db)
+	reserved := func() bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		_, ok := e.last[Fingerprint("SELECT 1")]
+		return ok
+	}
+	e.sem <- struct{}{}
+	e.Explain(ctx, "SELECT 1")
+	if reserved() {
+		t.Fatal("被丢弃的语句不应占用限流间隔")
+	}
+	<-e.sem
+	e.Explain(ctx, "SELECT 1")
+	if !reserved() {
+		t.Fatal("执行后同一指纹在间隔内应被限流")
+	}
+	waitExplain(e)
+}
+
+// TestExplainerConcurrent 确认并发数大于 1 时，同时到达的同指纹语句也只分析一次，检查与记录之间不会被其他语句插入。
+func TestExplainerConcurrent(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:explain_concurrent?mode=memory&cache=shared"), &gorm.Config{Logger: glogger.Discard})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	mu := &sync.Mutex{}
+	records := []record{}
+	ctx := otel.Ctx(context.Background(), testLogger{mu: mu, records: &records})
+	e := NewExplainer(db, WithExplainConcurrency(64))
+
+	start := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			e.Explain(ctx, fmt.Sprintf("SELECT %d", i))
+		}()
+	}
+	close(start)
+	wg.Wait()
+	waitExplain(e)
+	mu.Lock()
+	if len(records) != 1 {
+		t.Fatalf("同一指纹应只分析一次: %+v", records)
+	}
+	mu.Unlock()
+
+	// 另一条同指纹语句已通过限流、尚未取得名额时，本条语句不应再执行
+	e = NewExplainer(db, WithExplainConcurrency(2))
+	release, ok := e.reserve(Fingerprint("SELECT 1"))
+	if !ok {
+		t.Fatal("首次预留应成功")
+	}
+	e.Explain(ctx, "SELECT 2")
+	if len(e.sem) != 0 {
+		t.Fatal("已预留的指纹不应再次分析")
+	}
+	release()
+	if _, ok := e.reserve(Fingerprint("SELECT 1")); !ok {
+		t.Fatal("撤销后应能重新预留")
+	}
+}
+
+// waitExplain 等待进行中的分析结束，名额在分析结束后释放。
+func waitExplain(e *Explainer) {
+	deadline := time.Now().Add(2 * time.Second)
+	for len(e.sem) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
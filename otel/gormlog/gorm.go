@@ -4,6 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nzlov/utils/otel"
@@ -15,8 +22,15 @@ func init() {
 }
 
 type GormLogger struct {
-	LogLevel                  glogger.LogLevel
-	SlowThreshold             time.Duration
+	LogLevel      glogger.LogLevel
+	SlowThreshold time.Duration
+	// SlowThresholds 按表名设置慢查询阈值，覆盖 SlowThreshold，表名从 SQL 中解析。
+	SlowThresholds map[string]time.Duration
+	// InfoSampleRate Info 级别 SQL 日志的采样率（0~1），0 表示不采样、全部记录。
+	// 错误和慢查询总是记录。
+	InfoSampleRate float64
+	// Explainer 不为空时对慢 SELECT 异步执行 EXPLAIN，见 NewExplainer。
+	Explainer                 *Explainer
 	IgnoreRecordNotFoundError bool
 	ParameterizedQueries      bool
 }
@@ -60,35 +74,117 @@ func (l *GormLogger) Error(ctx context.Context, msg string, data ...any) {
 
 // Trace print sql message
 //
-//nolint:cyclop
+// SQL、耗时、行数、错误、是否慢查询以结构化属性输出：
+// db.statement、db.fingerprint、db.table、db.duration、db.rows、db.slow、db.error。
 func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
 	if l.LogLevel <= glogger.Silent {
 		return
 	}
 
 	elapsed := time.Since(begin)
+	var (
+		sql    string
+		rows   int64
+		called bool
+	)
+	get := func() (string, int64) {
+		if !called {
+			sql, rows = fc()
+			called = true
+		}
+		return sql, rows
+	}
+
+	threshold := l.SlowThreshold
+	// 未超过最小阈值时任何表都不会是慢查询，不需要调用 fc 取表名
+	if len(l.SlowThresholds) > 0 && elapsed > l.minThreshold() {
+		sql, _ := get()
+		if t, ok := l.SlowThresholds[Table(sql)]; ok {
+			threshold = t
+		}
+	}
+	slow := threshold != 0 && elapsed > threshold
+
 	switch {
 	case err != nil && l.LogLevel >= glogger.Error && (!errors.Is(err, glogger.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
-		sql, rows := fc()
-		if rows == -1 {
-			otel.Error(ctx, fmt.Sprintf("[%.3fms][rows:-]%s Err:%s", float64(elapsed.Nanoseconds())/1e6, sql, err))
-		} else {
-			otel.Error(ctx, fmt.Sprintf("[%.3fms][rows:%v]%s Err:%s", float64(elapsed.Nanoseconds())/1e6, rows, sql, err))
-		}
-	case elapsed > l.SlowThreshold && l.SlowThreshold != 0 && l.LogLevel >= glogger.Warn:
-		sql, rows := fc()
-		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)
-		if rows == -1 {
-			otel.Warn(ctx, fmt.Sprintf("[%.3fms][rows:-]%s Warn:%s", float64(elapsed.Nanoseconds())/1e6, sql, slowLog))
-		} else {
-			otel.Warn(ctx, fmt.Sprintf("[%.3fms][rows:%v]%s Warn:%s", float64(elapsed.Nanoseconds())/1e6, rows, sql, slowLog))
+		otel.Error(ctx, "sql error", attrs(get, elapsed, slow, err)...)
+	case slow && l.LogLevel >= glogger.Warn:
+		otel.Warn(ctx, "slow sql", append(attrs(get, elapsed, slow, nil), slog.Duration("db.slow_threshold", threshold))...)
+		if l.Explainer != nil {
+			sql, _ := get()
+			l.Explainer.Explain(ctx, sql)
 		}
-	case l.LogLevel == glogger.Info:
-		sql, rows := fc()
-		if rows == -1 {
-			otel.Info(ctx, fmt.Sprintf("[%.3fms][rows:-]%s", float64(elapsed.Nanoseconds())/1e6, sql))
-		} else {
-			otel.Info(ctx, fmt.Sprintf("[%.3fms][rows:%v]%s", float64(elapsed.Nanoseconds())/1e6, rows, sql))
+	case l.LogLevel == glogger.Info && l.sampled():
+		otel.Info(ctx, "sql", attrs(get, elapsed, slow, nil)...)
+	}
+}
+
+// minThreshold 返回 SlowThreshold 与 SlowThresholds 中最小的非零阈值，都为零时返回最大值。
+func (l *GormLogger) minThreshold() time.Duration {
+	m := time.Duration(math.MaxInt64)
+	if l.SlowThreshold != 0 {
+		m = l.SlowThreshold
+	}
+	for _, t := range l.SlowThresholds {
+		if t != 0 && t < m {
+			m = t
 		}
 	}
+	return m
+}
+
+func (l *GormLogger) sampled() bool {
+	return l.InfoSampleRate <= 0 || l.InfoSampleRate >= 1 || rand.Float64() < l.InfoSampleRate
+}
+
+func attrs(get func() (string, int64), elapsed time.Duration, slow bool, err error) []any {
+	sql, rows := get()
+	args := []any{
+		slog.String("db.statement", sql),
+		slog.String("db.fingerprint", Fingerprint(sql)),
+		slog.Duration("db.duration", elapsed),
+		slog.Bool("db.slow", slow),
+	}
+	if table := Table(sql); table != "" {
+		args = append(args, slog.String("db.table", table))
+	}
+	if rows != -1 {
+		args = append(args, slog.Int64("db.rows", rows))
+	}
+	if err != nil {
+		args = append(args, slog.String("db.error", err.Error()))
+	}
+	return args
+}
+
+var (
+	reString   = regexp.MustCompile(`'(?:[^']|'')*'`)
+	reNumber   = regexp.MustCompile(`\$\d+|\b\d+(?:\.\d+)?\b`)
+	reList     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	reRows     = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+	reSpace    = regexp.MustCompile(`\s+`)
+	reTable    = regexp.MustCompile("(?i)\\b(?:from|into|update)\\s+([`\"\\w.]+)")
+	tableQuote = strings.NewReplacer("`", "", `"`, "")
+)
+
+// Fingerprint 返回 SQL 的指纹，字面量、参数、IN 列表长度和批量插入行数不同的语句指纹相同，
+// 用于在日志中聚合同一类语句。
+func Fingerprint(sql string) string {
+	sql = reString.ReplaceAllString(sql, "?")
+	sql = reNumber.ReplaceAllString(sql, "?")
+	sql = reList.ReplaceAllString(sql, "(?)")
+	sql = reRows.ReplaceAllString(sql, "(?)")
+	sql = strings.ToLower(strings.TrimSpace(reSpace.ReplaceAllString(sql, " ")))
+	h := fnv.New64a()
+	h.Write([]byte(sql))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// Table 从 SQL 中解析第一个 FROM/INTO/UPDATE 后的表名，解析不到时返回空字符串。
+func Table(sql string) string {
+	m := reTable.FindStringSubmatch(sql)
+	if m == nil {
+		return ""
+	}
+	return tableQuote.Replace(m[1])
 }
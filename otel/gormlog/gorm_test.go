@@ -0,0 +1,122 @@
+package gormlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzlov/utils/otel"
+	glogger "gorm.io/gorm/logger"
+)
+
+type record struct {
+	level string
+	msg   string
+	attrs map[string]slog.Value
+}
+
+type testLogger struct {
+	mu      *sync.Mutex
+	records *[]record
+}
+
+func (l testLogger) add(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r := record{level: level, msg: msg, attrs: map[string]slog.Value{}}
+	for _, a := range args {
+		if attr, ok := a.(slog.Attr); ok {
+			r.attrs[attr.Key] = attr.Value
+		}
+	}
+	*l.records = append(*l.records, r)
+}
+
+func (l testLogger) Info(ctx context.Context, msg string, args ...any)  { l.add("info", msg, args) }
+func (l testLogger) Error(ctx context.Context, msg string, args ...any) { l.add("error", msg, args) }
+func (l testLogger) Warn(ctx context.Context, msg string, args ...any)  { l.add("warn", msg, args) }
+func (l testLogger) With(args ...any) otel.Logger                       { return l }
+func (l testLogger) Write(p []byte) (int, error)                        { return len(p), nil }
+func (l testLogger) Printf(f string, args ...any)                       { l.add("info", fmt.Sprintf(f, args...), nil) }
+
+// TestFingerprint 确认只有字面量、IN 列表长度、批量行数不同的语句指纹相同。
+func TestFingerprint(t *testing.T) {
+	same := [][2]string{
+		{`SELECT * FROM "users" WHERE id = 1 AND name = 'a''b'`, `select *  from "users" where id = 22 and name = 'c'`},
+		{`SELECT * FROM users WHERE id IN (1,2,3)`, `SELECT * FROM users WHERE id IN ($1, $2)`},
+		{`INSERT INTO t (a,b) VALUES (1,'x'),(2,'y')`, `INSERT INTO t (a,b) VALUES (3,'z')`},
+	}
+	for _, v := range same {
+		if Fingerprint(v[0]) != Fingerprint(v[1]) {
+			t.Fatalf("指纹应相同: %s | %s", v[0], v[1])
+		}
+	}
+	if Fingerprint("SELECT * FROM t1") == Fingerprint("SELECT * FROM t2") {
+		t.Fatal("不同表的指纹不应相同")
+	}
+	if tb := Table("UPDATE `orders` SET a = 1"); tb != "orders" {
+		t.Fatalf("表名解析不正确: %s", tb)
+	}
+}
+
+// TestTrace 确认输出结构化属性，并按表使用慢查询阈值。
+func TestTrace(t *testing.T) {
+	records := []record{}
+	ctx := otel.Ctx(context.Background(), testLogger{mu: &sync.Mutex{}, records: &records})
+	l := &GormLogger{
+		LogLevel:       glogger.Warn,
+		SlowThreshold:  time.Hour,
+		SlowThresholds: map[string]time.Duration{"orders": time.Millisecond},
+	}
+	begin := time.Now().Add(-10 * time.Millisecond)
+
+	l.Trace(ctx, begin, func() (string, int64) { return `SELECT * FROM "users"`, 3 }, nil)
+	l.Trace(ctx, begin, func() (string, int64) { return `SELECT * FROM "orders"`, -1 }, nil)
+	l.Trace(ctx, begin, func() (string, int64) { return `DELETE FROM users`, 0 }, errors.New("boom"))
+	if len(records) != 2 {
+		t.Fatalf("日志条数不正确: %+v", records)
+	}
+
+	slow := records[0]
+	if slow.level != "warn" || !slow.attrs["db.slow"].Bool() || slow.attrs["db.table"].String() != "orders" {
+		t.Fatalf("慢查询日志不正确: %+v", slow)
+	}
+	if _, ok := slow.attrs["db.rows"]; ok {
+		t.Fatal("行数未知时不应输出 db.rows")
+	}
+	if e := records[1]; e.level != "error" || e.attrs["db.error"].String() != "boom" || e.attrs["db.rows"].Int64() != 0 {
+		t.Fatalf("错误日志不正确: %+v", e)
+	}
+
+	records = records[:0]
+	l = &GormLogger{LogLevel: glogger.Info, InfoSampleRate: 0.000001}
+	for range 100 {
+		l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+	}
+	if len(records) > 5 {
+		t.Fatalf("采样后记录过多: %d", len(records))
+	}
+}
+
+// TestTraceSkipsFc 确认未超过最小阈值的非错误语句在 Warn 级别下不调用 fc。
+func TestTraceSkipsFc(t *testing.T) {
+	records := []record{}
+	ctx := otel.Ctx(context.Background(), testLogger{mu: &sync.Mutex{}, records: &records})
+	l := &GormLogger{
+		LogLevel:       glogger.Warn,
+		SlowThreshold:  time.Hour,
+		SlowThresholds: map[string]time.Duration{"orders": time.Minute},
+	}
+	called := 0
+	l.Trace(ctx, time.Now(), func() (string, int64) {
+		called++
+		return `SELECT * FROM "orders"`, 1
+	}, nil)
+	if called != 0 || len(records) != 0 {
+		t.Fatalf("快查询不应调用 fc: called=%d records=%+v", called, records)
+	}
+}
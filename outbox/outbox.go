@@ -0,0 +1,314 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nzlov/utils/db"
+	"github.com/nzlov/utils/slog"
+	"gorm.io/gorm"
+)
+
+var ErrNotInTx = errors.New("outbox message must be added within db.Tx")
+
+const (
+	StatusPending = iota
+	StatusSent
+	StatusDead
+)
+
+// Message 是写入 outbox 表的一条待发送消息。
+// Key 相同的消息按写入顺序投递，Key 为空的消息不保证顺序。
+type Message struct {
+	db.Model
+
+	Topic     string
+	Key       string `gorm:"index"`
+	Payload   []byte
+	Headers   db.Map[string, string] `gorm:"serializer:json"`
+	Status    int                    `gorm:"index"`
+	Attempts  int
+	NextAt    time.Time
+	SentAt    *time.Time
+	LastError string
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Migrate 创建 outbox 表。
+func Migrate(ctx context.Context) error {
+	gdb, err := db.TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	return gdb.AutoMigrate(&Message{})
+}
+
+// Add 在当前事务中写入一条消息，与业务数据一起提交或回滚，由 Relay 在提交后投递。
+// 必须在 db.Tx 中调用，否则返回 ErrNotInTx。
+func Add(ctx context.Context, topic, key string, payload []byte, headers map[string]string) error {
+	if !db.InTx(ctx) {
+		return ErrNotInTx
+	}
+	gdb, err := db.TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	return gdb.Create(&Message{
+		Topic:   topic,
+		Key:     key,
+		Payload: payload,
+		Headers: headers,
+		NextAt:  time.Now(),
+	}).Error
+}
+
+// AddJSON 将 v 序列化为 JSON 后调用 Add。
+func AddJSON(ctx context.Context, topic, key string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return Add(ctx, topic, key, payload, map[string]string{"Content-Type": "application/json"})
+}
+
+// Publisher 将消息投递到下游，返回 nil 表示投递成功。
+// Relay 保证至少投递一次，下游需要根据 Message.ID 自行去重。
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+type PublisherFunc func(ctx context.Context, msg *Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+type RelayConfig struct {
+	BatchSize   int
+	Interval    time.Duration
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	Retention   time.Duration
+}
+
+type RelayOption func(*RelayConfig)
+
+// WithRelayBatchSize 设置每次轮询读取的消息数量。
+func WithRelayBatchSize(size int) RelayOption {
+	return func(cfg *RelayConfig) {
+		if size > 0 {
+			cfg.BatchSize = size
+		}
+	}
+}
+
+// WithRelayInterval 设置没有消息时的轮询间隔。
+func WithRelayInterval(d time.Duration) RelayOption {
+	return func(cfg *RelayConfig) {
+		if d > 0 {
+			cfg.Interval = d
+		}
+	}
+}
+
+// WithRelayMaxAttempts 设置单条消息最大投递次数，超过后标记为 StatusDead 不再重试，
+// 同 Key 的后续消息随之继续投递。0 表示无限重试。
+func WithRelayMaxAttempts(n int) RelayOption {
+	return func(cfg *RelayConfig) {
+		if n >= 0 {
+			cfg.MaxAttempts = n
+		}
+	}
+}
+
+// WithRelayBackoff 设置投递失败后的指数退避区间。
+func WithRelayBackoff(min, max time.Duration) RelayOption {
+	return func(cfg *RelayConfig) {
+		if min > 0 {
+			cfg.MinBackoff = min
+		}
+		if max >= cfg.MinBackoff {
+			cfg.MaxBackoff = max
+		}
+	}
+}
+
+// WithRelayRetention 设置已发送消息的保留时长，Run 会定期清理过期消息，0 表示不清理。
+func WithRelayRetention(d time.Duration) RelayOption {
+	return func(cfg *RelayConfig) {
+		if d >= 0 {
+			cfg.Retention = d
+		}
+	}
+}
+
+// Relay 轮询 outbox 表并把待发送消息交给 Publisher。
+// 同一张表只应运行一个 Relay，多个实例并发运行时可能重复投递。
+type Relay struct {
+	cfg RelayConfig
+	pub Publisher
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewRelay(pub Publisher, ops ...RelayOption) *Relay {
+	cfg := RelayConfig{
+		BatchSize:  100,
+		Interval:   time.Second,
+		MinBackoff: time.Second,
+		MaxBackoff: 5 * time.Minute,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	return &Relay{
+		cfg:  cfg,
+		pub:  pub,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Run 持续投递直到 ctx 取消或调用 Shutdown，可直接作为 otel.Run 使用。
+// ctx 中需要通过 db.Ctx 注入数据库，每个 Relay 只能 Run 一次。
+func (r *Relay) Run(ctx context.Context) error {
+	defer close(r.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lastClean := time.Time{}
+	for {
+		n, err := r.Once(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.For(ctx).ErrorContext(ctx, "outbox relay failed", "err", err)
+		}
+
+		if r.cfg.Retention > 0 && time.Since(lastClean) > r.cfg.Retention/10 {
+			if err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				slog.For(ctx).ErrorContext(ctx, "outbox cleanup failed", "err", err)
+			}
+			lastClean = time.Now()
+		}
+
+		if n == r.cfg.BatchSize && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.cfg.Interval):
+		}
+	}
+}
+
+// Shutdown 停止 Run 并等待当前批次处理完成。
+func (r *Relay) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Once 处理一批待发送消息，返回本批实际尝试投递的消息数。
+// 每个 Key 只读取最早一条未发送的消息，且只读取已到重试时间的消息，
+// 因此某个 Key 持续失败时不会占满批次、阻塞其它 Key 的投递。
+// 一轮中有消息投递成功时会继续读取同 Key 的后续消息，直到达到 BatchSize。
+func (r *Relay) Once(ctx context.Context) (int, error) {
+	gdb, err := db.TryFor(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	q := gdb.Statement.Quote
+	table, key, id := q(Message{}.TableName()), q("key"), q("id")
+	pendingFirst := key + " = '' OR NOT EXISTS (SELECT 1 FROM " + table + " o2 WHERE o2." + key + " = " + table + "." + key +
+		" AND o2.status = ? AND o2." + id + " < " + table + "." + id + " AND o2.deleted_at IS NULL)"
+
+	n := 0
+	for n < r.cfg.BatchSize {
+		msgs := []*Message{}
+		if err := gdb.Where("status = ? AND next_at <= ?", StatusPending, time.Now()).
+			Where(pendingFirst, StatusPending).
+			Order("id").Limit(r.cfg.BatchSize - n).Find(&msgs).Error; err != nil {
+			return n, err
+		}
+
+		sent := 0
+		for _, msg := range msgs {
+			if err := ctx.Err(); err != nil {
+				return n, err
+			}
+			if err := r.deliver(ctx, gdb, msg); err != nil {
+				return n, err
+			}
+			n++
+			if msg.Status == StatusSent {
+				sent++
+			}
+		}
+		if sent == 0 {
+			break
+		}
+	}
+	return n, nil
+}
+
+func (r *Relay) deliver(ctx context.Context, gdb *gorm.DB, msg *Message) error {
+	perr := r.pub.Publish(ctx, msg)
+	msg.Attempts++
+
+	updates := map[string]any{"attempts": msg.Attempts}
+	if perr == nil {
+		now := time.Now()
+		msg.Status = StatusSent
+		msg.SentAt = &now
+		updates["status"] = msg.Status
+		updates["sent_at"] = now
+		updates["last_error"] = ""
+	} else {
+		if r.cfg.MaxAttempts > 0 && msg.Attempts >= r.cfg.MaxAttempts {
+			msg.Status = StatusDead
+			updates["status"] = msg.Status
+		}
+		backoff := r.cfg.MinBackoff << min(msg.Attempts-1, 20)
+		if backoff <= 0 || backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+		msg.NextAt = time.Now().Add(backoff)
+		msg.LastError = perr.Error()
+		updates["next_at"] = msg.NextAt
+		updates["last_error"] = msg.LastError
+	}
+
+	return gdb.Model(&Message{}).Where("id = ?", msg.ID).Updates(updates).Error
+}
+
+// Cleanup 删除超过保留时长的已发送消息。
+func (r *Relay) Cleanup(ctx context.Context) error {
+	gdb, err := db.TryFor(ctx)
+	if err != nil {
+		return err
+	}
+	return gdb.Unscoped().
+		Where("status = ? AND sent_at < ?", StatusSent, time.Now().Add(-r.cfg.Retention)).
+		Delete(&Message{}).Error
+}
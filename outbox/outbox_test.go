@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nzlov/utils/db"
+	"gorm.io/gorm"
+)
+
+func testCtx(t *testing.T) context.Context {
+	t.Helper()
+
+	dbName := fmt.Sprintf("file:outbox_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	gdb, err := gorm.Open(sqlite.Open(dbName), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	ctx := db.Ctx(context.Background(), gdb)
+	if err := Migrate(ctx); err != nil {
+		t.Fatalf("迁移 outbox 表失败: %v", err)
+	}
+	return ctx
+}
+
+// TestAddRequiresTx 避免在事务外写 outbox，那样就失去了与业务数据同生共死的意义。
+func TestAddRequiresTx(t *testing.T) {
+	ctx := testCtx(t)
+	if err := Add(ctx, "t", "k", []byte("x"), nil); !errors.Is(err, ErrNotInTx) {
+		t.Fatalf("返回错误不正确: got=%v want=%v", err, ErrNotInTx)
+	}
+}
+
+// TestRelayOrderPerKey 验证同 Key 前一条失败时后续消息不会越过它先被投递，失败后按退避重试。
+func TestRelayOrderPerKey(t *testing.T) {
+	ctx := testCtx(t)
+
+	if err := db.Tx(ctx, func(ctx context.Context) error {
+		for _, v := range []struct{ key, payload string }{{"a", "a1"}, {"a", "a2"}, {"b", "b1"}} {
+			if err := Add(ctx, "topic", v.key, []byte(v.payload), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("写入消息失败: %v", err)
+	}
+
+	var sent []string
+	fail := true
+	relay := NewRelay(PublisherFunc(func(ctx context.Context, msg *Message) error {
+		if string(msg.Payload) == "a1" && fail {
+			fail = false
+			return errors.New("publish failed")
+		}
+		sent = append(sent, string(msg.Payload))
+		return nil
+	}), WithRelayBackoff(time.Millisecond, time.Millisecond))
+
+	if _, err := relay.Once(ctx); err != nil {
+		t.Fatalf("投递失败: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "b1" {
+		t.Fatalf("第一批投递结果不正确: %v", sent)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, err := relay.Once(ctx); err != nil {
+		t.Fatalf("投递失败: %v", err)
+	}
+	if len(sent) != 3 || sent[1] != "a1" || sent[2] != "a2" {
+		t.Fatalf("重试后投递顺序不正确: %v", sent)
+	}
+}
+
+// TestRelayStuckKey 验证某个 Key 持续失败且积压超过 BatchSize 时，其它 Key 的消息仍能投递。
+func TestRelayStuckKey(t *testing.T) {
+	ctx := testCtx(t)
+
+	if err := db.Tx(ctx, func(ctx context.Context) error {
+		for i := range 5 {
+			if err := Add(ctx, "topic", "stuck", []byte(fmt.Sprint("s", i)), nil); err != nil {
+				return err
+			}
+		}
+		return Add(ctx, "topic", "ok", []byte("ok"), nil)
+	}); err != nil {
+		t.Fatalf("写入消息失败: %v", err)
+	}
+
+	var sent []string
+	relay := NewRelay(PublisherFunc(func(ctx context.Context, msg *Message) error {
+		if msg.Key == "stuck" {
+			return errors.New("publish failed")
+		}
+		sent = append(sent, string(msg.Payload))
+		return nil
+	}), WithRelayBatchSize(2), WithRelayBackoff(time.Hour, time.Hour))
+
+	for range 3 {
+		if _, err := relay.Once(ctx); err != nil {
+			t.Fatalf("投递失败: %v", err)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "ok" {
+		t.Fatalf("其它 Key 的消息应被投递: %v", sent)
+	}
+}
@@ -0,0 +1,103 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nzlov/utils/mqtt"
+	"github.com/nzlov/utils/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// MQTTPublisher 以 Message.Topic 为主题发布到 MQTT。
+type MQTTPublisher struct {
+	Config   *mqtt.Config
+	QoS      byte
+	Retained bool
+	Timeout  time.Duration
+}
+
+func (p *MQTTPublisher) Publish(ctx context.Context, msg *Message) error {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	token := p.Config.Mqtt().Publish(msg.Topic, p.QoS, p.Retained, msg.Payload)
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("mqtt publish %s timeout", msg.Topic)
+	}
+	return token.Error()
+}
+
+// RedisStreamPublisher 将消息追加到 Redis Stream，Stream 为空时使用 Message.Topic。
+type RedisStreamPublisher struct {
+	Config *redis.Config
+	Stream string
+	MaxLen int64
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, msg *Message) error {
+	stream := p.Stream
+	if stream == "" {
+		stream = msg.Topic
+	}
+	values := map[string]any{
+		"id":      msg.ID,
+		"topic":   msg.Topic,
+		"key":     msg.Key,
+		"payload": msg.Payload,
+	}
+	for k, v := range msg.Headers {
+		values["h:"+k] = v
+	}
+	return p.Config.Redis().XAdd(ctx, &goredis.XAddArgs{
+		Stream: stream,
+		MaxLen: p.MaxLen,
+		Approx: p.MaxLen > 0,
+		Values: values,
+	}).Err()
+}
+
+// WebhookPublisher 将 Payload 作为请求体 POST 到 URL，2xx 视为成功。
+// 消息元信息通过 X-Outbox-ID、X-Outbox-Topic、X-Outbox-Key 请求头传递。
+type WebhookPublisher struct {
+	URL    string
+	Header map[string]string
+	Client *http.Client
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, msg *Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return err
+	}
+	for k, v := range p.Header {
+		req.Header.Set(k, v)
+	}
+	for k, v := range msg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("X-Outbox-ID", strconv.FormatUint(uint64(msg.ID), 10))
+	req.Header.Set("X-Outbox-Topic", msg.Topic)
+	req.Header.Set("X-Outbox-Key", msg.Key)
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook failed code[%d]:%s", resp.StatusCode, string(data))
+	}
+	return nil
+}
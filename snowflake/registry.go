@@ -0,0 +1,244 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nzlov/utils"
+	"github.com/nzlov/utils/db"
+	"github.com/nzlov/utils/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNoFreeNode = errors.New("snowflake: no free node id")
+
+// Registry 以租约方式分配节点号，租约过期前需要续期，过期后节点号可以被其他实例占用。
+type Registry interface {
+	// Acquire 占用一个空闲节点号。
+	Acquire(ctx context.Context, owner string, ttl time.Duration) (int64, error)
+	// Renew 续期，租约已被其他实例占用时返回 ErrLeaseLost。
+	Renew(ctx context.Context, owner string, node int64, ttl time.Duration) error
+	Release(ctx context.Context, owner string, node int64) error
+}
+
+type lease struct {
+	registry Registry
+	owner    string
+	ttl      time.Duration
+	expires  atomic.Int64 // 租约到期时间，UnixNano
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// lost 在续期失败且租约已过期时返回 true，此时节点号可能已被其他实例占用。
+func (l *lease) lost() bool {
+	return time.Now().UnixNano() >= l.expires.Load()
+}
+
+func (l *lease) renew(ctx context.Context, node int64) {
+	defer close(l.done)
+	t := time.NewTicker(l.ttl / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+		}
+		start := time.Now()
+		err := l.registry.Renew(ctx, l.owner, node, l.ttl)
+		if errors.Is(err, ErrLeaseLost) {
+			l.expires.Store(0)
+			return
+		}
+		if err == nil {
+			l.expires.Store(start.Add(l.ttl).UnixNano())
+		}
+	}
+}
+
+// NewLeasedNode 从 registry 获取节点号并创建 Node，后台每 ttl/3 续期一次。
+// 租约丢失后 Generate 返回 ErrLeaseLost，需要重新创建。停止使用时调用 Close 释放节点号。
+func NewLeasedNode(ctx context.Context, registry Registry, ttl time.Duration, ops ...Option) (*Node, error) {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	l := &lease{
+		registry: registry,
+		owner:    utils.RandString(16),
+		ttl:      ttl,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	start := time.Now()
+	id, err := registry.Acquire(ctx, l.owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	n, err := NewNode(id, ops...)
+	if err != nil {
+		registry.Release(ctx, l.owner, id)
+		return nil, err
+	}
+	l.expires.Store(start.Add(ttl).UnixNano())
+	n.lease = l
+	go l.renew(context.WithoutCancel(ctx), id)
+	return n, nil
+}
+
+// Close 停止续期并释放节点号，对 NewNode 创建的 Node 无操作。
+func (n *Node) Close(ctx context.Context) error {
+	l := n.lease
+	if l == nil {
+		return nil
+	}
+	l.once.Do(func() { close(l.stop) })
+	<-l.done
+	return l.registry.Release(ctx, l.owner, n.node)
+}
+
+// NodeLease 节点号租约表。
+type NodeLease struct {
+	Node      int64 `gorm:"primaryKey;autoIncrement:false"`
+	Owner     string
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (NodeLease) TableName() string {
+	return "snowflake_nodes"
+}
+
+// DBRegistry 使用 snowflake_nodes 表分配节点号，DB 为空时使用 db.For(ctx)。
+// 租约到期时间取各实例本地时间，实例间时钟偏差需要远小于 ttl。
+// 使用前需要 AutoMigrate(&NodeLease{})。
+type DBRegistry struct {
+	DB *gorm.DB
+}
+
+func (r *DBRegistry) db(ctx context.Context) (*gorm.DB, error) {
+	if r.DB != nil {
+		return r.DB.WithContext(ctx), nil
+	}
+	return db.TryFor(db.WithoutTx(ctx))
+}
+
+func (r *DBRegistry) Acquire(ctx context.Context, owner string, ttl time.Duration) (int64, error) {
+	gdb, err := r.db(ctx)
+	if err != nil {
+		return 0, err
+	}
+	leases := []NodeLease{}
+	if err := gdb.Order("node").Find(&leases).Error; err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	used := map[int64]bool{}
+	for _, l := range leases {
+		used[l.Node] = true
+	}
+	// 优先使用从未分配过的节点号，冲突说明被其他实例抢先，继续尝试下一个
+	for id := int64(0); id <= MaxNode; id++ {
+		if used[id] {
+			continue
+		}
+		rs := gdb.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&NodeLease{Node: id, Owner: owner, ExpiresAt: now.Add(ttl)})
+		if rs.Error != nil {
+			return 0, rs.Error
+		}
+		if rs.RowsAffected == 1 {
+			return id, nil
+		}
+	}
+	for _, l := range leases {
+		if l.ExpiresAt.After(now) {
+			continue
+		}
+		rs := gdb.Model(&NodeLease{}).
+			Where("node = ? AND expires_at < ?", l.Node, now).
+			Updates(map[string]any{"owner": owner, "expires_at": now.Add(ttl)})
+		if rs.Error != nil {
+			return 0, rs.Error
+		}
+		if rs.RowsAffected == 1 {
+			return l.Node, nil
+		}
+	}
+	return 0, ErrNoFreeNode
+}
+
+func (r *DBRegistry) Renew(ctx context.Context, owner string, node int64, ttl time.Duration) error {
+	gdb, err := r.db(ctx)
+	if err != nil {
+		return err
+	}
+	rs := gdb.Model(&NodeLease{}).Where("node = ? AND owner = ?", node, owner).
+		Update("expires_at", time.Now().Add(ttl))
+	if rs.Error != nil {
+		return rs.Error
+	}
+	if rs.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *DBRegistry) Release(ctx context.Context, owner string, node int64) error {
+	gdb, err := r.db(ctx)
+	if err != nil {
+		return err
+	}
+	return gdb.Where("node = ? AND owner = ?", node, owner).Delete(&NodeLease{}).Error
+}
+
+// RedisRegistry 使用 Redis 键 Prefix+节点号 分配节点号，键的过期时间即租约时长。
+type RedisRegistry struct {
+	Config *redis.Config
+	Prefix string
+}
+
+func (r *RedisRegistry) key(node int64) string {
+	return r.Prefix + strconv.FormatInt(node, 10)
+}
+
+func (r *RedisRegistry) Acquire(ctx context.Context, owner string, ttl time.Duration) (int64, error) {
+	c := r.Config.Redis()
+	for id := int64(0); id <= MaxNode; id++ {
+		ok, err := c.SetNX(ctx, r.key(id), owner, ttl).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return id, nil
+		}
+	}
+	return 0, ErrNoFreeNode
+}
+
+// 仅在键仍属于 owner 时续期或删除，避免误操作其他实例的租约
+const (
+	redisRenewScript   = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+	redisReleaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+func (r *RedisRegistry) Renew(ctx context.Context, owner string, node int64, ttl time.Duration) error {
+	n, err := r.Config.Redis().Eval(ctx, redisRenewScript, []string{r.key(node)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *RedisRegistry) Release(ctx context.Context, owner string, node int64) error {
+	return r.Config.Redis().Eval(ctx, redisReleaseScript, []string{r.key(node)}, owner).Err()
+}
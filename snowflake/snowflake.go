@@ -0,0 +1,152 @@
+package snowflake
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	NodeBits = 10
+	SeqBits  = 12
+
+	MaxNode = 1<<NodeBits - 1
+	maxSeq  = 1<<SeqBits - 1
+)
+
+var (
+	ErrInvalidNode   = errors.New("snowflake: node id out of range")
+	ErrClockBackward = errors.New("snowflake: clock moved backwards")
+	ErrLeaseLost     = errors.New("snowflake: node lease lost")
+)
+
+// DefaultEpoch 为 2024-01-01 UTC，41 位毫秒时间戳可用到 2093 年。
+var DefaultEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type Config struct {
+	Epoch       time.Time
+	MaxBackward time.Duration
+	Now         func() time.Time
+}
+
+type Option func(*Config)
+
+// WithEpoch 设置起始时间，同一业务的所有节点必须一致。
+func WithEpoch(epoch time.Time) Option {
+	return func(cfg *Config) {
+		cfg.Epoch = epoch
+	}
+}
+
+// WithMaxBackward 设置可容忍的时钟回拨时长，回拨不超过 d 时等待时钟追上，超过时 Generate 返回 ErrClockBackward。
+// 默认 10ms。
+func WithMaxBackward(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.MaxBackward = d
+	}
+}
+
+// WithNow 设置获取当前时间的函数，默认 time.Now。
+func WithNow(now func() time.Time) Option {
+	return func(cfg *Config) {
+		cfg.Now = now
+	}
+}
+
+// Node 生成 64 位可排序 ID：1 位符号位 | 41 位毫秒时间戳 | 10 位节点号 | 12 位序号。
+// 每个节点每毫秒最多生成 4096 个 ID，用完后等待下一毫秒。
+// 同一时刻同一节点号只能有一个 Node 在使用，多实例部署时通过 NewLeasedNode 自动分配节点号。
+type Node struct {
+	cfg   Config
+	epoch int64
+	node  int64
+
+	mu   sync.Mutex
+	last int64
+	seq  int64
+
+	lease *lease
+}
+
+func NewNode(node int64, ops ...Option) (*Node, error) {
+	if node < 0 || node > MaxNode {
+		return nil, ErrInvalidNode
+	}
+	cfg := Config{
+		Epoch:       DefaultEpoch,
+		MaxBackward: 10 * time.Millisecond,
+		Now:         time.Now,
+	}
+	for _, op := range ops {
+		op(&cfg)
+	}
+	return &Node{cfg: cfg, epoch: cfg.Epoch.UnixMilli(), node: node}, nil
+}
+
+// ID 返回节点号。
+func (n *Node) ID() int64 {
+	return n.node
+}
+
+func (n *Node) now() int64 {
+	return n.cfg.Now().UnixMilli() - n.epoch
+}
+
+// Generate 生成一个新 ID。
+func (n *Node) Generate() (int64, error) {
+	if n.lease != nil && n.lease.lost() {
+		return 0, ErrLeaseLost
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	ts := n.now()
+	if ts < n.last {
+		back := time.Duration(n.last-ts) * time.Millisecond
+		if back > n.cfg.MaxBackward {
+			return 0, ErrClockBackward
+		}
+		time.Sleep(back)
+		if ts = n.now(); ts < n.last {
+			return 0, ErrClockBackward
+		}
+	}
+
+	if ts == n.last {
+		n.seq = (n.seq + 1) & maxSeq
+		if n.seq == 0 {
+			for ts <= n.last {
+				time.Sleep(100 * time.Microsecond)
+				ts = n.now()
+			}
+		}
+	} else {
+		n.seq = 0
+	}
+	n.last = ts
+	return ts<<(NodeBits+SeqBits) | n.node<<SeqBits | n.seq, nil
+}
+
+// GenerateString 生成十进制字符串形式的 ID，可直接替换 TimeID。
+func (n *Node) GenerateString() (string, error) {
+	id, err := n.Generate()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// Parse 拆解 ID 中的时间、节点号与序号，Epoch 需要与生成时一致。
+func (n *Node) Parse(id int64) (t time.Time, node, seq int64) {
+	return Parse(id, n.cfg.Epoch)
+}
+
+// Parse 拆解 ID 中的时间、节点号与序号。
+func Parse(id int64, epoch time.Time) (t time.Time, node, seq int64) {
+	t = time.UnixMilli(id>>(NodeBits+SeqBits) + epoch.UnixMilli())
+	node = id >> SeqBits & MaxNode
+	seq = id & maxSeq
+	return
+}
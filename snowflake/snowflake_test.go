@@ -0,0 +1,114 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/nzlov/utils/db"
+	"gorm.io/gorm"
+)
+
+func TestGenerate(t *testing.T) {
+	n, err := NewNode(5)
+	if err != nil {
+		t.Fatalf("创建节点失败: %v", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = map[int64]bool{}
+		last = map[int]int64{}
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5000; j++ {
+				id, err := n.Generate()
+				if err != nil {
+					t.Errorf("生成失败: %v", err)
+					return
+				}
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("ID 重复: %d", id)
+				}
+				seen[id] = true
+				if id <= last[i] {
+					t.Errorf("同一协程内 ID 未递增: %d <= %d", id, last[i])
+				}
+				last[i] = id
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	id, _ := n.Generate()
+	ts, node, _ := n.Parse(id)
+	if node != 5 || time.Since(ts) > time.Second {
+		t.Fatalf("解析结果不正确: %v %d", ts, node)
+	}
+}
+
+func TestClockBackward(t *testing.T) {
+	now := time.Now()
+	n, _ := NewNode(1, WithMaxBackward(0), WithNow(func() time.Time { return now }))
+	if _, err := n.Generate(); err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+	now = now.Add(-time.Second)
+	if _, err := n.Generate(); !errors.Is(err, ErrClockBackward) {
+		t.Fatalf("时钟回拨应返回错误: %v", err)
+	}
+}
+
+func TestDBRegistry(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := gdb.AutoMigrate(&NodeLease{}); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	ctx := db.Ctx(context.Background(), gdb)
+	reg := &DBRegistry{}
+
+	a, err := NewLeasedNode(ctx, reg, time.Minute)
+	if err != nil {
+		t.Fatalf("获取节点号失败: %v", err)
+	}
+	b, err := NewLeasedNode(ctx, reg, time.Minute)
+	if err != nil {
+		t.Fatalf("获取节点号失败: %v", err)
+	}
+	if a.ID() == b.ID() {
+		t.Fatalf("节点号重复: %d", a.ID())
+	}
+	if _, err := a.Generate(); err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+
+	if err := a.Close(ctx); err != nil {
+		t.Fatalf("释放失败: %v", err)
+	}
+	c, err := NewLeasedNode(ctx, reg, time.Minute)
+	if err != nil {
+		t.Fatalf("获取节点号失败: %v", err)
+	}
+	if c.ID() != a.ID() {
+		t.Fatalf("释放的节点号应被复用: %d != %d", c.ID(), a.ID())
+	}
+
+	if err := reg.Renew(ctx, "other", b.ID(), time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("非持有者续期应返回 ErrLeaseLost: %v", err)
+	}
+	b.Close(ctx)
+	c.Close(ctx)
+}
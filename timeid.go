@@ -5,6 +5,7 @@ import (
 	"time"
 )
 
+// Deprecated: 多台机器或同一时刻并发调用时会重复，使用 snowflake 包、ULID 或 UUIDv7 代替。
 func TimeID() string {
 	return strconv.FormatInt(time.Now().UnixNano(), 10)
 }
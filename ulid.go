@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidID = errors.New("invalid id")
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var crockfordDec = func() [256]byte {
+	d := [256]byte{}
+	for i := range d {
+		d[i] = 0xff
+	}
+	for i := 0; i < len(crockford); i++ {
+		d[crockford[i]] = byte(i)
+		d[crockford[i]|0x20] = byte(i)
+	}
+	return d
+}()
+
+var ulidState struct {
+	sync.Mutex
+	ms   uint64
+	rand [10]byte
+}
+
+// ULID 生成 26 位 Crockford Base32 编码的 ULID，前 48 位为毫秒时间戳，字典序即时间序。
+// 同一毫秒内生成的 ULID 在上一个随机数基础上加 1，保证单进程内严格递增。
+func ULID() string {
+	ms := uint64(time.Now().UnixMilli())
+
+	ulidState.Lock()
+	if ms <= ulidState.ms {
+		ms = ulidState.ms
+		for i := len(ulidState.rand) - 1; i >= 0; i-- {
+			ulidState.rand[i]++
+			if ulidState.rand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		ulidState.ms = ms
+		rand.Read(ulidState.rand[:])
+	}
+	var b [16]byte
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	copy(b[6:], ulidState.rand[:])
+	ulidState.Unlock()
+
+	// 128 位按 5 位一组编码，首字符只占 3 位
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// ParseULIDTime 解析 ULID 中的时间戳。
+func ParseULIDTime(s string) (time.Time, error) {
+	if len(s) != 26 || crockfordDec[s[0]] > 7 {
+		return time.Time{}, ErrInvalidID
+	}
+	ms := uint64(0)
+	for i := 0; i < 10; i++ {
+		v := crockfordDec[s[i]]
+		if v == 0xff {
+			return time.Time{}, ErrInvalidID
+		}
+		ms = ms<<5 | uint64(v)
+	}
+	for i := 10; i < 26; i++ {
+		if crockfordDec[s[i]] == 0xff {
+			return time.Time{}, ErrInvalidID
+		}
+	}
+	// 首字符只有 3 位，前 10 个字符正好是 48 位时间戳
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// UUIDv7 生成 RFC 9562 UUIDv7，前 48 位为毫秒时间戳，适合作为数据库主键。
+func UUIDv7() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// ParseUUIDv7Time 解析 UUIDv7 中的时间戳。
+func ParseUUIDv7Time(s string) (time.Time, error) {
+	u, err := uuid.Parse(s)
+	if err != nil || u.Version() != 7 {
+		return time.Time{}, ErrInvalidID
+	}
+	sec, nsec := u.Time().UnixTime()
+	return time.Unix(sec, nsec), nil
+}
@@ -157,6 +157,16 @@ func (c *Config) PushStrCheck(data string) {
 	c.ch <- data
 }
 
+// TryPushStr 非阻塞推送，缓冲已满时丢弃并返回 false。
+func (c *Config) TryPushStr(data string) bool {
+	select {
+	case c.ch <- data:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Config) Write(p []byte) (n int, err error) {
 	c.PushStrCheck(string(p))
 	return len(p), nil